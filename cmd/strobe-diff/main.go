@@ -0,0 +1,101 @@
+// Command strobe-diff compares the transcripts recorded by the two parties
+// of a Strobe protocol (see strobe.Recorder) and prints the first operation
+// where they diverge.
+//
+// Usage:
+//
+//	strobe-diff initiator.json responder.json
+//
+// Each file contains a single test vector in the format of
+// strobe/test_vectors/test_vectors.json. The exit status is 0 if the
+// transcripts agree, 1 if they diverge and 2 on error.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mimoo/StrobeGo/strobe"
+)
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Fprintln(os.Stderr, "usage: strobe-diff initiator.json responder.json")
+		os.Exit(2)
+	}
+	a, err := readTranscript(os.Args[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	b, err := readTranscript(os.Args[2])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	d, err := strobe.DiffTranscripts(a, b)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if d == nil {
+		fmt.Println("transcripts agree")
+		return
+	}
+	printDivergence(os.Stdout, d, os.Args[1], os.Args[2])
+	os.Exit(1)
+}
+
+// readTranscript reads a test vector from a JSON file.
+func readTranscript(path string) (vector strobe.TestVector, err error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return vector, err
+	}
+	if err := json.Unmarshal(content, &vector); err != nil {
+		return vector, fmt.Errorf("%s: %w", path, err)
+	}
+	return vector, nil
+}
+
+// printDivergence prints both views of the diverging operation.
+func printDivergence(w io.Writer, d *strobe.Divergence, nameA, nameB string) {
+	fmt.Fprintln(w, d)
+	for _, side := range []struct {
+		name string
+		op   *strobe.TranscriptOp
+	}{{nameA, d.A}, {nameB, d.B}} {
+		fmt.Fprintf(w, "\n%s:\n", side.name)
+		if side.op == nil {
+			fmt.Fprintln(w, "  (missing)")
+			continue
+		}
+		for i, chunk := range side.op.Chunks {
+			fmt.Fprintf(w, "  #%d %s meta=%t stream=%t", side.op.Index+i, chunk.OpName, chunk.OpMeta, chunk.OpStream)
+			if chunk.OpName == "init" {
+				fmt.Fprintf(w, " custom_string=%q security=%d", chunk.OpCustomString, chunk.OpSecurity)
+				if chunk.OpWidth != 0 {
+					fmt.Fprintf(w, " width=%d", chunk.OpWidth)
+				}
+				if chunk.OpRounds != 0 {
+					fmt.Fprintf(w, " rounds=%d", chunk.OpRounds)
+				}
+				if chunk.OpPermutation != "" {
+					fmt.Fprintf(w, " permutation=%s", chunk.OpPermutation)
+				}
+			}
+			if chunk.OpInputLength != 0 {
+				fmt.Fprintf(w, " input_length=%d", chunk.OpInputLength)
+			}
+			if chunk.OpInputData != "" {
+				fmt.Fprintf(w, " input_data=%s", chunk.OpInputData)
+			}
+			if chunk.OpOutput != "" {
+				fmt.Fprintf(w, " output=%s", chunk.OpOutput)
+			}
+			fmt.Fprintf(w, "\n    state_after=%s\n", chunk.OpStateAfter)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package strobe
+
+import (
+	"crypto/cipher"
+)
+
+const (
+	// AEADNonceSize is the size of the nonces used by the AEAD returned by NewAEAD.
+	AEADNonceSize = 12
+	// AEADMinTagSize is the smallest tag size accepted by NewAEADWithTagSize.
+	AEADMinTagSize = 12
+)
+
+// AEAD implements crypto/cipher.AEAD on top of the Strobe AEAD flow (see
+// Send_AEAD). Every call runs on a copy of a keyed state in which the nonce
+// is absorbed as meta-AD, so the same AEAD can be used concurrently.
+// As with the allocation-free API, the output can reuse the storage of the
+// input (in[:0]) but must not overlap it otherwise.
+type AEAD struct {
+	template Strobe // initialized and keyed state
+	tagSize  int
+}
+
+// NewAEAD returns a cipher.AEAD (an *AEAD) keyed with `key`, with MACLEN-byte
+// tags. The key must be at least `security` bits long.
+func NewAEAD(key []byte, customization string, security int) (cipher.AEAD, error) {
+	return NewAEADWithTagSize(key, customization, security, MACLEN)
+}
+
+// NewAEADWithTagSize is like NewAEAD but produces tags of `tagSize` bytes,
+// which must be at least AEADMinTagSize.
+func NewAEADWithTagSize(key []byte, customization string, security, tagSize int) (cipher.AEAD, error) {
+	if tagSize < AEADMinTagSize {
+		return nil, ErrInvalidTagSize
+	}
+	s, err := TryInitStrobe(customization, security)
+	if err != nil {
+		return nil, err
+	}
+	if len(key)*8 < security {
+		return nil, ErrInvalidKeySize
+	}
+	s.KEY(key)
+	return &AEAD{template: s, tagSize: tagSize}, nil
+}
+
+// NonceSize returns the size of the nonce that must be passed to Seal and Open.
+func (a *AEAD) NonceSize() int {
+	return AEADNonceSize
+}
+
+// Overhead returns the size of the authentication tag.
+func (a *AEAD) Overhead() int {
+	return a.tagSize
+}
+
+// begin returns a copy of the keyed state in which the nonce is absorbed.
+func (a *AEAD) begin(nonce []byte) Strobe {
+	if len(nonce) != AEADNonceSize {
+		panic("strobe: incorrect nonce length given to AEAD")
+	}
+	s := a.template
+	s.mustOperate(metaFlags(OpAD, true), nil, nonce, 0)
+	return s
+}
+
+// seal encrypts `plaintext` into `out` and writes the tag in `tag`.
+func (a *AEAD) seal(out, tag, nonce, plaintext, additionalData []byte) {
+	s := a.begin(nonce)
+	s.mustOperate(flag(OpSendENC), out, plaintext, 0)
+	s.mustOperate(flag(OpAD), nil, additionalData, 0)
+	s.mustOperate(flag(OpSendMAC), tag, nil, len(tag))
+}
+
+// open decrypts `ciphertext` into `out` and checks `tag`. On failure, `out`
+// is zeroed.
+func (a *AEAD) open(out, nonce, ciphertext, tag, additionalData []byte) error {
+	s := a.begin(nonce)
+	s.mustOperate(flag(OpRecvENC), out, ciphertext, 0)
+	s.mustOperate(flag(OpAD), nil, additionalData, 0)
+	if s.mustOperate(flag(OpRecvMAC), nil, tag, 0) != 0 {
+		for i := range out {
+			out[i] = 0
+		}
+		return ErrAuthenticationFailed
+	}
+	return nil
+}
+
+// Seal encrypts and authenticates `plaintext`, authenticates `additionalData`
+// and appends the result to `dst`, see cipher.AEAD.
+func (a *AEAD) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	ret, out := sliceForAppend(dst, len(plaintext)+a.tagSize)
+	a.seal(out[:len(plaintext)], out[len(plaintext):], nonce, plaintext, additionalData)
+	return ret
+}
+
+// Open decrypts and authenticates `ciphertext`, authenticates
+// `additionalData` and, if successful, appends the resulting plaintext to
+// `dst`, see cipher.AEAD.
+func (a *AEAD) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != AEADNonceSize {
+		panic("strobe: incorrect nonce length given to AEAD")
+	}
+	if len(ciphertext) < a.tagSize {
+		return nil, ErrAuthenticationFailed
+	}
+	tag := ciphertext[len(ciphertext)-a.tagSize:]
+	ciphertext = ciphertext[:len(ciphertext)-a.tagSize]
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	if err := a.open(out, nonce, ciphertext, tag, additionalData); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// SealDetached is like Seal but returns the authentication tag separately
+// instead of appending it to the ciphertext.
+func (a *AEAD) SealDetached(dst, nonce, plaintext, additionalData []byte) (ciphertext, tag []byte) {
+	ret, out := sliceForAppend(dst, len(plaintext))
+	tag = make([]byte, a.tagSize)
+	a.seal(out, tag, nonce, plaintext, additionalData)
+	return ret, tag
+}
+
+// OpenDetached is like Open but takes the authentication tag separately.
+func (a *AEAD) OpenDetached(dst, nonce, ciphertext, tag, additionalData []byte) ([]byte, error) {
+	if len(nonce) != AEADNonceSize {
+		panic("strobe: incorrect nonce length given to AEAD")
+	}
+	if len(tag) != a.tagSize {
+		return nil, ErrAuthenticationFailed
+	}
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	if err := a.open(out, nonce, ciphertext, tag, additionalData); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
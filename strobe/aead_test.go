@@ -0,0 +1,94 @@
+package strobe
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAEAD(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	nonce := make([]byte, AEADNonceSize)
+	ad := []byte("additional data")
+
+	for _, security := range []int{128, 256} {
+		for _, tagSize := range []int{AEADMinTagSize, MACLEN, 32} {
+			aead, err := NewAEADWithTagSize(key, "myAEAD", security, tagSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if aead.Overhead() != tagSize || aead.NonceSize() != AEADNonceSize {
+				t.Fatal("invalid overhead or nonce size")
+			}
+
+			sealed := aead.Seal(nil, nonce, message, ad)
+			if len(sealed) != len(message)+tagSize {
+				t.Fatal("invalid ciphertext length")
+			}
+
+			// it is the Send_AEAD flow, with the nonce as meta-AD
+			s := InitStrobe("myAEAD", security)
+			s.KEY(key)
+			s.AD(true, nonce)
+			expected := s.Send_ENC_unauthenticated(false, message)
+			s.AD(false, ad)
+			expected = append(expected, s.Send_MAC(false, tagSize)...)
+			if !bytes.Equal(sealed, expected) {
+				t.Fatal("Seal does not follow the Send_AEAD flow")
+			}
+
+			opened, err := aead.Open(nil, nonce, sealed, ad)
+			if err != nil || !bytes.Equal(opened, message) {
+				t.Fatal("cannot open sealed message")
+			}
+
+			// in place
+			buf := append([]byte{}, message...)
+			buf = aead.Seal(buf[:0], nonce, buf, ad)
+			if !bytes.Equal(buf, sealed) {
+				t.Fatal("cannot seal in place")
+			}
+			buf, err = aead.Open(buf[:0], nonce, buf, ad)
+			if err != nil || !bytes.Equal(buf, message) {
+				t.Fatal("cannot open in place")
+			}
+
+			// detached
+			ciphertext, tag := aead.(*AEAD).SealDetached(nil, nonce, message, ad)
+			if !bytes.Equal(append(ciphertext, tag...), sealed) {
+				t.Fatal("SealDetached does not match Seal")
+			}
+			opened, err = aead.(*AEAD).OpenDetached(nil, nonce, ciphertext, tag, ad)
+			if err != nil || !bytes.Equal(opened, message) {
+				t.Fatal("cannot open detached message")
+			}
+
+			// tampering
+			otherNonce := append([]byte{}, nonce...)
+			otherNonce[0] ^= 1
+			for i, test := range []struct{ nonce, sealed, ad []byte }{
+				{otherNonce, sealed, ad},
+				{nonce, sealed[:len(sealed)-1], ad},
+				{nonce, sealed[:tagSize-1], ad},
+				{nonce, sealed, ad[1:]},
+				{nonce, append([]byte{sealed[0] ^ 1}, sealed[1:]...), ad},
+			} {
+				dst := make([]byte, 0, len(test.sealed))
+				if _, err := aead.Open(dst, test.nonce, test.sealed, test.ad); err != ErrAuthenticationFailed {
+					t.Fatalf("%d: expected ErrAuthenticationFailed, got %v", i, err)
+				}
+				for _, b := range dst[:cap(dst)] {
+					if b != 0 {
+						t.Fatalf("%d: output is not zeroed on failure", i)
+					}
+				}
+			}
+		}
+	}
+
+	if _, err := NewAEAD(key[:16], "myAEAD", 256); err != ErrInvalidKeySize {
+		t.Fatal("expected ErrInvalidKeySize, got", err)
+	}
+	if _, err := NewAEADWithTagSize(key, "myAEAD", 128, 8); err != ErrInvalidTagSize {
+		t.Fatal("expected ErrInvalidTagSize, got", err)
+	}
+}
@@ -0,0 +1,251 @@
+package strobe
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+//
+// Batches
+//
+// A server handling many independent sessions can run the same operation on
+// many Strobe states at once with OperateBatch. The states advance in
+// lockstep, and the permutations of the states running on Keccak-f[1600]
+// (or Keccak-p[1600, nr]) are computed four at a time, with AVX2 on amd64.
+//
+
+var (
+	// ErrBatchInputs is returned by OperateBatch when it does not have one
+	// input per state.
+	ErrBatchInputs = errors.New("strobe: a batch needs one input per state")
+	// ErrBatchDuplicate is returned by OperateBatch when a state appears
+	// twice in the batch.
+	ErrBatchDuplicate = errors.New("strobe: a state appears twice in a batch")
+)
+
+// PRFBatch is like calling PRF(outputLen) on each of `states`, see
+// OperateBatch.
+func PRFBatch(states []*Strobe, outputLen int) [][]byte {
+	out, err := OperateBatch(states, false, OpPRF, nil, outputLen)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// OperateBatch runs the same operation on each of `states`, with the input
+// inputs[i] for states[i], and returns their outputs. It computes the same
+// thing as calling states[i].TryOperateOp(meta, op, inputs[i], length, false)
+// for each state, but faster. Operations that only require a length take a
+// nil `inputs`.
+// If the operation is invalid for one of the states, OperateBatch returns an
+// error and leaves every state untouched.
+func OperateBatch(states []*Strobe, meta bool, op Operation, inputs [][]byte, length int) ([][]byte, error) {
+	// operation is valid?
+	if !op.valid() {
+		return nil, ErrUnknownOperation
+	}
+	flags := metaFlags(op, meta)
+
+	// does the operation requires a length?
+	if length < 0 {
+		return nil, ErrNegativeLength
+	}
+	if flags.needsLength() {
+		if length == 0 {
+			return nil, ErrLengthRequired
+		}
+		if inputs != nil {
+			return nil, ErrBatchInputs
+		}
+	} else {
+		if length != 0 {
+			return nil, ErrLengthNotAllowed
+		}
+		if len(inputs) != len(states) {
+			return nil, ErrBatchInputs
+		}
+	}
+
+	// can every state run the operation?
+	next := make([]int, len(states))
+	seen := make(map[*Strobe]bool, len(states))
+	for i, s := range states {
+		if seen[s] {
+			return nil, ErrBatchDuplicate
+		}
+		seen[s] = true
+		if s.poisoned {
+			return nil, ErrStatePoisoned
+		}
+		if s.locked {
+			return nil, ErrStreamInProgress
+		}
+		if s.protocol != nil {
+			n, err := s.checkProtocol(flags)
+			if err != nil {
+				return nil, err
+			}
+			next[i] = n
+		}
+	}
+
+	// begin the operations
+	ops := make([]batchOp, len(states))
+	for i, s := range states {
+		if s.protocol != nil {
+			s.protocolState = next[i]
+		}
+		ops[i].s = s
+		ops[i].header = s.opHeader(flags)
+		ops[i].src = ops[i].header[:]
+		s.curFlags = flags
+	}
+	duplexBatch(ops, false, false, flags&(flagC|flagK) != 0)
+	for _, s := range states {
+		if s.tracer != nil {
+			s.traceBeginOp(flags)
+		}
+	}
+
+	// Operation
+	cAfter, cBefore := flags.cAfter(), flags.cBefore()
+
+	var zeroes []byte
+	if flags.needsLength() {
+		zeroes = make([]byte, length)
+	}
+	for i := range ops {
+		op := &ops[i]
+		op.src = zeroes
+		if inputs != nil {
+			op.src = inputs[i]
+		}
+		if flags.hasOutput() || flags.isRecvMAC() {
+			op.output = make([]byte, len(op.src))
+			op.dst = op.output
+		}
+		if op.s.tracer != nil && op.s.traceData {
+			op.input = append([]byte{}, op.src...)
+		}
+	}
+	duplexBatch(ops, cBefore, cAfter, false)
+
+	outputs := make([][]byte, len(states))
+	for i, s := range states {
+		var failures byte
+		if flags.hasOutput() {
+			outputs[i] = ops[i].output
+		}
+		if flags.isRecvMAC() {
+			// Check MAC: all output bytes must be 0
+			for _, b := range ops[i].output {
+				failures |= b
+			}
+			if failures != 0 {
+				s.macFailed()
+			}
+			// 0 if correct, else the OR of the output bytes
+			outputs[i] = []byte{failures}
+		}
+		if s.tracer != nil {
+			processed := length
+			if !flags.needsLength() {
+				processed = len(inputs[i])
+			}
+			s.traceOperate(flags, ops[i].input, outputs[i], processed, failures, false)
+		}
+	}
+	return outputs, nil
+}
+
+// batchOp is the progress of a duplex call in a batch.
+type batchOp struct {
+	s        *Strobe
+	src, dst []byte // what's left to process
+	header   [2]byte
+	input    []byte // a copy of the input, for the tracer
+	output   []byte // the whole output
+}
+
+// duplexBatch runs the duplex call of each operation of `ops`, as
+// ops[i].s.duplex(ops[i].dst, ops[i].src, cbefore, cafter, forceF) would.
+func duplexBatch(ops []batchOp, cbefore, cafter, forceF bool) {
+	done := make([]bool, len(ops))
+	var full []*Strobe
+	for {
+		full = full[:0]
+		for i := range ops {
+			op := &ops[i]
+			if done[i] {
+				continue
+			}
+			if len(op.src) > 0 {
+				todo := op.s.duplexBlock(op.dst, op.src, cbefore, cafter)
+				op.src = op.src[todo:]
+				if op.dst != nil {
+					op.dst = op.dst[todo:]
+				}
+				if op.s.pos == op.s.StrobeR {
+					full = append(full, op.s)
+					continue
+				}
+			}
+			done[i] = true
+			if forceF && op.s.pos != 0 {
+				full = append(full, op.s)
+			}
+		}
+		if len(full) == 0 {
+			return
+		}
+		runFBatch(full)
+	}
+}
+
+// runFBatch runs runF on each of `states`.
+func runFBatch(states []*Strobe) {
+	// group the Keccak-p[1600, nr] states by number of rounds
+	var groups map[int][]*Strobe
+	for _, s := range states {
+		s.padF()
+		if k, ok := s.perm.(keccakPermutation); ok && k.width == 1600 {
+			if groups == nil {
+				groups = make(map[int][]*Strobe)
+			}
+			groups[k.rounds] = append(groups[k.rounds], s)
+		} else {
+			s.perm.Permute(s.state[:s.perm.Size()])
+		}
+	}
+
+	var a [25][4]uint64
+	for rounds, group := range groups {
+		for len(group) > 1 {
+			n := len(group)
+			if n > 4 {
+				n = 4
+			}
+			for j, s := range group[:n] {
+				for i := range a {
+					a[i][j] = binary.LittleEndian.Uint64(s.state[8*i:])
+				}
+			}
+			keccakF1600x4(&a, rounds)
+			for j, s := range group[:n] {
+				for i := range a {
+					binary.LittleEndian.PutUint64(s.state[8*i:], a[i][j])
+				}
+			}
+			group = group[n:]
+		}
+		for _, s := range group {
+			s.perm.Permute(s.state[:s.perm.Size()])
+		}
+	}
+
+	for _, s := range states {
+		s.pos = 0
+		s.posBegin = 0
+	}
+}
@@ -0,0 +1,199 @@
+package strobe
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// batchStates returns `n` states with different configurations and positions,
+// and their copies.
+func batchStates(t *testing.T, n int) (batch, copies []*Strobe) {
+	configs := []Config{
+		{Security: 128},
+		{Security: 256},
+		{Security: 128, Rounds: 12},
+		{Security: 128, Width: 800},
+		{Security: 128, Permutation: NewXoodoo()},
+	}
+	for i := 0; i < n; i++ {
+		s, err := TryInitStrobeWithConfig("batch", configs[i%len(configs)])
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.AD(false, bytes.Repeat([]byte{byte(i)}, 7*i))
+		batch = append(batch, &s)
+		copies = append(copies, s.Clone())
+	}
+	return batch, copies
+}
+
+func TestOperateBatch(t *testing.T) {
+	steps := []struct {
+		op     Operation
+		meta   bool
+		length int
+		input  func(i int) []byte
+	}{
+		{op: OpKEY, input: func(i int) []byte { return bytes.Repeat([]byte{byte(i)}, 32) }},
+		{op: OpAD, meta: true, input: func(i int) []byte { return bytes.Repeat([]byte{1}, 300*i) }},
+		{op: OpSendENC, input: func(i int) []byte { return bytes.Repeat([]byte{2}, 5+60*i) }},
+		{op: OpRecvENC, input: func(i int) []byte { return message }},
+		{op: OpSendCLR, input: func(i int) []byte { return message[:i] }},
+		{op: OpPRF, length: 500},
+		{op: OpSendMAC, length: 16},
+		{op: OpRecvMAC, input: func(i int) []byte { return make([]byte, 16) }},
+		{op: OpRATCHET, length: 32},
+		{op: OpPRF, length: 1},
+	}
+
+	for n := 0; n <= 11; n++ {
+		batch, copies := batchStates(t, n)
+		for _, step := range steps {
+			var inputs [][]byte
+			if step.input != nil {
+				for i := 0; i < n; i++ {
+					inputs = append(inputs, step.input(i))
+				}
+			}
+			outputs, err := OperateBatch(batch, step.meta, step.op, inputs, step.length)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, s := range copies {
+				var input []byte
+				if inputs != nil {
+					input = inputs[i]
+				}
+				expected, err := s.TryOperateOp(step.meta, step.op, input, step.length, false)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(outputs[i], expected) {
+					t.Fatalf("the output of %s on state %d of %d differs from TryOperateOp", step.op, i, n)
+				}
+				if batch[i].debugPrintState() != s.debugPrintState() {
+					t.Fatalf("the state %d of %d differs from TryOperateOp after %s", i, n, step.op)
+				}
+			}
+		}
+	}
+}
+
+func TestPRFBatch(t *testing.T) {
+	batch, copies := batchStates(t, 6)
+	outputs := PRFBatch(batch, 64)
+	for i, s := range copies {
+		if !bytes.Equal(outputs[i], s.PRF(64)) {
+			t.Fatalf("PRFBatch differs from PRF on state %d", i)
+		}
+	}
+}
+
+func TestOperateBatchErrors(t *testing.T) {
+	batch, copies := batchStates(t, 5)
+	inputs := make([][]byte, len(batch))
+
+	if _, err := OperateBatch(batch, false, OpAD, inputs[1:], 0); err != ErrBatchInputs {
+		t.Fatal("a batch missing an input should fail")
+	}
+	if _, err := OperateBatch(batch, false, OpPRF, inputs, 16); err != ErrBatchInputs {
+		t.Fatal("a batch of PRF with inputs should fail")
+	}
+	if _, err := OperateBatch(batch, false, OpPRF, nil, 0); err != ErrLengthRequired {
+		t.Fatal("a batch of PRF without a length should fail")
+	}
+	if _, err := OperateBatch(batch, false, OpPRF, nil, -5); err != ErrNegativeLength {
+		t.Fatal("a batch of PRF with a negative length should fail")
+	}
+	if _, err := OperateBatch(append(batch, batch[0]), false, OpAD, append(inputs, nil), 0); err != ErrBatchDuplicate {
+		t.Fatal("a batch with a state twice should fail")
+	}
+
+	// a state that cannot run the operation stops the whole batch
+	batch[3].SetProtocol(MustCompileProtocol("KEY"))
+	_, err := OperateBatch(batch, false, OpAD, inputs, 0)
+	if !errors.Is(err, ErrProtocolViolation) {
+		t.Fatal("a batch violating a protocol should fail")
+	}
+	for i, s := range copies {
+		if batch[i].debugPrintState() != s.debugPrintState() {
+			t.Fatalf("a failed batch modified the state %d", i)
+		}
+	}
+	if _, err := OperateBatch(batch, false, OpKEY, inputs, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !batch[3].ProtocolComplete() {
+		t.Fatal("a batch should advance the protocol of its states")
+	}
+
+	// failed MACs poison their states
+	batch, _ = batchStates(t, 2)
+	for _, s := range batch {
+		s.SetAbortOnFailure(true)
+	}
+	outputs, err := OperateBatch(batch, false, OpRecvMAC, [][]byte{make([]byte, 16), {1}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if outputs[0][0] == 0 || outputs[1][0] == 0 || !batch[0].Poisoned() || !batch[1].Poisoned() {
+		t.Fatal("a batch should fail the MACs")
+	}
+	if _, err := OperateBatch(batch, false, OpAD, [][]byte{nil, nil}, 0); err != ErrStatePoisoned {
+		t.Fatal("a batch with a poisoned state should fail")
+	}
+}
+
+func TestOperateBatchTracer(t *testing.T) {
+	r1, r2 := NewRecorder("batch"), NewRecorder("batch")
+	s1, err := r1.InitStrobe("batch", 128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := r2.InitStrobe("batch", 128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := InitStrobe("other", 128)
+	batch := []*Strobe{&s1, &other}
+
+	OperateBatch(batch, false, OpAD, [][]byte{message, nil}, 0)
+	OperateBatch(batch, false, OpSendENC, [][]byte{message, message}, 0)
+	PRFBatch(batch, 200)
+	s2.AD(false, message)
+	s2.Send_ENC_unauthenticated(false, message)
+	s2.PRF(200)
+
+	if !reflect.DeepEqual(r1.TestVector(), r2.TestVector()) {
+		t.Fatal("a batch should be traced like the operations it runs")
+	}
+}
+
+func BenchmarkPRFBatch(b *testing.B) {
+	states := make([]*Strobe, 64)
+	for i := range states {
+		s := InitStrobe("batch", 128)
+		states[i] = &s
+	}
+	b.SetBytes(int64(len(states) * 32))
+	for i := 0; i < b.N; i++ {
+		PRFBatch(states, 32)
+	}
+}
+
+// BenchmarkPRFSequential runs the PRFs of BenchmarkPRFBatch one at a time.
+func BenchmarkPRFSequential(b *testing.B) {
+	states := make([]*Strobe, 64)
+	for i := range states {
+		s := InitStrobe("batch", 128)
+		states[i] = &s
+	}
+	b.SetBytes(int64(len(states) * 32))
+	for i := 0; i < b.N; i++ {
+		for _, s := range states {
+			s.PRF(32)
+		}
+	}
+}
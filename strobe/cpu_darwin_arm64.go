@@ -0,0 +1,13 @@
+//go:build arm64 && !appengine && !gccgo
+// +build arm64,!appengine,!gccgo
+
+package strobe
+
+import "syscall"
+
+// hasSHA3 asks the kernel whether the CPU has the SHA3 extension, which all
+// Apple silicon has.
+func hasSHA3() bool {
+	v, err := syscall.Sysctl("hw.optional.armv8_2_sha3")
+	return err == nil && len(v) > 0 && v[0] == 1
+}
@@ -0,0 +1,30 @@
+//go:build arm64 && !appengine && !gccgo
+// +build arm64,!appengine,!gccgo
+
+package strobe
+
+import (
+	"encoding/binary"
+	"os"
+)
+
+const (
+	_AT_HWCAP   = 16      // type of the hardware capabilities in the auxiliary vector
+	_HWCAP_SHA3 = 1 << 17 // hardware capability bit of the SHA3 extension
+)
+
+// hasSHA3 reads the hardware capabilities that the kernel passed to the
+// process in its auxiliary vector. It returns false if they are unavailable.
+func hasSHA3() bool {
+	auxv, err := os.ReadFile("/proc/self/auxv")
+	if err != nil {
+		return false
+	}
+	for ; len(auxv) >= 16; auxv = auxv[16:] {
+		tag, val := binary.LittleEndian.Uint64(auxv), binary.LittleEndian.Uint64(auxv[8:])
+		if tag == _AT_HWCAP {
+			return val&_HWCAP_SHA3 != 0
+		}
+	}
+	return false
+}
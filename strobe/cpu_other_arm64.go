@@ -0,0 +1,10 @@
+//go:build arm64 && !linux && !darwin && !appengine && !gccgo
+// +build arm64,!linux,!darwin,!appengine,!gccgo
+
+package strobe
+
+// hasSHA3 returns false on the systems where the SHA3 extension cannot be
+// detected, so that keccakF1600 runs keccakF1600Scalar.
+func hasSHA3() bool {
+	return false
+}
@@ -0,0 +1,167 @@
+package strobe
+
+import (
+	"errors"
+	"fmt"
+)
+
+//
+// Transcript comparison
+//
+// Both parties of a protocol keep identical Strobe states: each of their
+// operations must be paired with the same operation on the other side, or
+// with its mirror for transport operations (send_ENC on one side and
+// recv_ENC on the other). DiffTranscripts finds the first operation where two
+// recorded transcripts stop agreeing.
+//
+
+// TranscriptOp is an operation of a transcript, with its streamed chunks
+// merged.
+type TranscriptOp struct {
+	Index  int               // index of the first chunk in the TestVector
+	Chunks []VectorOperation // the first chunk followed by its continuations
+}
+
+// Divergence describes the first operation on which two transcripts differ.
+type Divergence struct {
+	Position int           // position of the operation in the merged transcripts (0 is init)
+	Reason   string        // "init", "flags", "length", "state_after" or "missing operation"
+	A, B     *TranscriptOp // the operation on each side, nil if it is missing
+}
+
+func (d *Divergence) String() string {
+	return fmt.Sprintf("transcripts diverge at operation %d: %s", d.Position, d.Reason)
+}
+
+// mergeTranscript parses a transcript and merges the streamed chunks of its
+// operations. The first operation is init.
+func mergeTranscript(vector TestVector) ([]TranscriptOp, error) {
+	if len(vector.Operations) == 0 || vector.Operations[0].OpName != "init" {
+		return nil, errors.New("strobe: a transcript must start with init")
+	}
+	var ops []TranscriptOp
+	for i, op := range vector.Operations {
+		if i > 0 {
+			if _, err := ParseOperation(op.OpName); err != nil {
+				return nil, fmt.Errorf("strobe: invalid operation %d: %w", i, err)
+			}
+		}
+		if op.OpStream && len(ops) > 1 {
+			last := &ops[len(ops)-1]
+			last.Chunks = append(last.Chunks, op)
+			continue
+		}
+		ops = append(ops, TranscriptOp{Index: i, Chunks: []VectorOperation{op}})
+	}
+	return ops, nil
+}
+
+// Operation returns the operation.
+func (op *TranscriptOp) Operation() Operation {
+	operation, _ := ParseOperation(op.Chunks[0].OpName)
+	return operation
+}
+
+// Length returns the length of the data processed by the operation (or the
+// requested length for PRF, send_MAC and RATCHET) over all its chunks.
+func (op *TranscriptOp) Length() int {
+	length := 0
+	for _, chunk := range op.Chunks {
+		if chunk.OpInputLength != 0 {
+			length += chunk.OpInputLength
+		} else {
+			length += len(chunk.OpInputData) / 2
+		}
+	}
+	return length
+}
+
+// StateAfter returns the state after the last chunk.
+func (op *TranscriptOp) StateAfter() string {
+	return op.Chunks[len(op.Chunks)-1].OpStateAfter
+}
+
+// DiffTranscripts compares the transcripts recorded by the two parties of a
+// protocol (see Recorder) and returns the first operation where they differ,
+// or nil if they agree. Transport operations are paired with their mirror on
+// the other side, and their flags are compared as absorbed in the state,
+// that is after taking the role (I0) of each side into account: the role
+// recorded in "init" (see SetRole), or else the one set by the first
+// transport operation.
+func DiffTranscripts(a, b TestVector) (*Divergence, error) {
+	opsA, err := mergeTranscript(a)
+	if err != nil {
+		return nil, err
+	}
+	opsB, err := mergeTranscript(b)
+	if err != nil {
+		return nil, err
+	}
+
+	initA, initB := opsA[0].Chunks[0], opsB[0].Chunks[0]
+	if initA.OpCustomString != initB.OpCustomString || initA.OpPermutation != initB.OpPermutation ||
+		initA.config().withDefaults() != initB.config().withDefaults() || initA.OpStateAfter != initB.OpStateAfter {
+		return &Divergence{Position: 0, Reason: "init", A: &opsA[0], B: &opsB[0]}, nil
+	}
+
+	roleA, err := initA.role()
+	if err != nil {
+		return nil, err
+	}
+	roleB, err := initB.role()
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i < len(opsA) || i < len(opsB); i++ {
+		if i >= len(opsA) || i >= len(opsB) {
+			d := &Divergence{Position: i, Reason: "missing operation"}
+			if i < len(opsA) {
+				d.A = &opsA[i]
+			} else {
+				d.B = &opsB[i]
+			}
+			return d, nil
+		}
+		opA, opB := &opsA[i], &opsB[i]
+		d := &Divergence{Position: i, A: opA, B: opB}
+
+		// flags, as absorbed in the state
+		flagsA := metaFlags(opA.Operation(), opA.Chunks[0].OpMeta)
+		flagsB := metaFlags(opB.Operation(), opB.Chunks[0].OpMeta)
+		if flagsA&flagT != 0 && flagsB&flagT != 0 {
+			if roleA == NoRole {
+				roleA = Role(flagsA & flagI)
+			}
+			if roleB == NoRole {
+				roleB = Role(flagsB & flagI)
+			}
+			// a send must be paired with a recv
+			if flagsA&flagI == flagsB&flagI || absorbedFlags(flagsA, roleA) != absorbedFlags(flagsB, roleB) {
+				d.Reason = "flags"
+				return d, nil
+			}
+		} else if flagsA != flagsB {
+			d.Reason = "flags"
+			return d, nil
+		}
+
+		if opA.Length() != opB.Length() {
+			d.Reason = "length"
+			return d, nil
+		}
+		if opA.StateAfter() != opB.StateAfter() {
+			d.Reason = "state_after"
+			return d, nil
+		}
+	}
+	return nil, nil
+}
+
+// absorbedFlags returns the flags of a transport operation as absorbed in
+// the state of a party with the role `r`.
+func absorbedFlags(flags flag, r Role) flag {
+	if r == Symmetric {
+		return flags &^ flagI
+	}
+	return flags ^ flag(r)
+}
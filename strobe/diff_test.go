@@ -0,0 +1,96 @@
+package strobe
+
+import (
+	"testing"
+)
+
+// handshake records both sides of a small protocol. The responder receives
+// the encrypted message in chunks.
+func handshake(t *testing.T, keyA, keyB []byte, responderSends bool) (TestVector, TestVector) {
+	recorderA, recorderB := NewRecorder("initiator"), NewRecorder("responder")
+	a, err := recorderA.InitStrobe("myProtocol", 128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := recorderB.InitStrobe("myProtocol", 128)
+
+	a.KEY(keyA)
+	b.KEY(keyB)
+	a.Send_CLR(true, []byte("hello"))
+	b.Recv_CLR(true, []byte("hello"))
+	ciphertext := a.Send_ENC_unauthenticated(false, message)
+	b.Operate(false, "recv_ENC", ciphertext[:10], 0, false)
+	b.Operate(false, "recv_ENC", ciphertext[10:], 0, true)
+	if responderSends {
+		b.Send_MAC(false, 16)
+	} else {
+		b.Recv_MAC(false, a.Send_MAC(false, 16))
+	}
+	a.PRF(16)
+	b.PRF(16)
+	return recorderA.TestVector(), recorderB.TestVector()
+}
+
+func TestDiffTranscripts(t *testing.T) {
+	key := []byte("key")
+
+	initiator, responder := handshake(t, key, key, false)
+	if d, err := DiffTranscripts(initiator, responder); d != nil || err != nil {
+		t.Fatal("transcripts of the same protocol diverge:", d, err)
+	}
+
+	initiator, responder = handshake(t, key, []byte("kez"), false)
+	d, err := DiffTranscripts(initiator, responder)
+	if err != nil || d == nil || d.Position != 1 || d.Reason != "state_after" {
+		t.Fatal("expected a divergence of the state at KEY, got", d, err)
+	}
+	if d.A.Operation() != OpKEY || d.B.Operation() != OpKEY {
+		t.Fatal("unexpected operations", d.A, d.B)
+	}
+
+	initiator, responder = handshake(t, key, key, true)
+	d, err = DiffTranscripts(initiator, responder)
+	if err != nil || d == nil || d.Position != 4 || d.Reason != "flags" {
+		t.Fatal("expected a divergence of the flags at send_MAC, got", d, err)
+	}
+
+	responder.Operations = responder.Operations[:len(responder.Operations)-2]
+	d, err = DiffTranscripts(initiator, responder)
+	if err != nil || d == nil || d.Position != 4 || d.Reason != "missing operation" || d.B != nil {
+		t.Fatal("expected a missing operation, got", d, err)
+	}
+
+	if _, err := DiffTranscripts(TestVector{}, responder); err == nil {
+		t.Fatal("expected an error for an empty transcript")
+	}
+}
+
+func TestDiffTranscriptsRoles(t *testing.T) {
+	for _, roles := range [][2]Role{{Responder, Initiator}, {Symmetric, Symmetric}} {
+		recorderA, recorderB := NewRecorder("a"), NewRecorder("b")
+		a, _ := recorderA.InitStrobe("myProtocol", 128)
+		b, _ := recorderB.InitStrobe("myProtocol", 128)
+		a.KEY([]byte("key"))
+		b.KEY([]byte("key"))
+		a.SetRole(roles[0])
+		b.SetRole(roles[1])
+
+		// the party sending first is not the initiator
+		a.Send_CLR(false, []byte("hello"))
+		b.Recv_CLR(false, []byte("hello"))
+		a.Recv_MAC(false, b.Send_MAC(false, 16))
+
+		transcriptA, transcriptB := recorderA.TestVector(), recorderB.TestVector()
+		if transcriptA.Operations[0].OpRole != roles[0].String() {
+			t.Fatal("the role is not recorded in init:", transcriptA.Operations[0].OpRole)
+		}
+		for _, transcript := range []TestVector{transcriptA, transcriptB} {
+			if err := Replay(transcript); err != nil {
+				t.Fatal(roles, err)
+			}
+		}
+		if d, err := DiffTranscripts(transcriptA, transcriptB); d != nil || err != nil {
+			t.Fatal("transcripts of the same protocol diverge:", roles, d, err)
+		}
+	}
+}
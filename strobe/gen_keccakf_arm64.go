@@ -0,0 +1,131 @@
+//go:build ignore
+// +build ignore
+
+// This program generates keccakf_arm64_scalar.s, the implementation of
+// keccakF1600 for the arm64 CPUs without the SHA3 extension. Run it with
+//
+//	go generate
+//
+// The 25 lanes do not fit in the general purpose registers next to the
+// column parities and the θ effects, so the rounds alternate between `a` and
+// a scratch state `t`, and the number of rounds must be even. The ρ rotations
+// and the θ effects use the shifted operands and BIC of arm64.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+)
+
+var out bytes.Buffer
+
+func emit(format string, args ...interface{}) {
+	fmt.Fprintf(&out, "\t"+format+"\n", args...)
+}
+
+// lane returns the index of the lane (x, y).
+func lane(x, y int) int {
+	return x%5 + 5*(y%5)
+}
+
+// rho returns the offsets of the ρ step, indexed by lane.
+func rho() [25]int {
+	var r [25]int
+	x, y := 1, 0
+	for t := 0; t < 24; t++ {
+		r[lane(x, y)] = (t + 1) * (t + 2) / 2 % 64
+		x, y = y, (2*x+3*y)%5
+	}
+	return r
+}
+
+// Registers: R0 and R1 point to `a` and `t`, R2 to the next round constant,
+// R3 counts the pairs of rounds and R4 holds a round constant. R5-R9 hold the
+// column parities, R10-R14 the θ effect of each column, R15-R17, R19 and R20
+// a row after ρ and π, and R21 and R22 are temporaries.
+var (
+	c = [5]string{"R5", "R6", "R7", "R8", "R9"}
+	d = [5]string{"R10", "R11", "R12", "R13", "R14"}
+	b = [5]string{"R15", "R16", "R17", "R19", "R20"}
+)
+
+// round emits a round from the lanes at `src` to the lanes at `dst`.
+func round(src, dst string) {
+	r := rho()
+
+	emit("// θ")
+	for x := 0; x < 5; x++ {
+		emit("MOVD %d(%s), %s", lane(x, 0)*8, src, c[x])
+		for y := 1; y < 5; y++ {
+			emit("MOVD %d(%s), R22", lane(x, y)*8, src)
+			emit("EOR R22, %s, %s", c[x], c[x])
+		}
+	}
+	for x := 0; x < 5; x++ {
+		// d[x] = c[x-1] ^ (c[x+1] <<< 1)
+		emit("EOR %s@>63, %s, %s", c[(x+1)%5], c[(x+4)%5], d[x])
+	}
+
+	for y := 0; y < 5; y++ {
+		emit("// ρ and π, row %d", y)
+		for x := 0; x < 5; x++ {
+			// the lane (x, y) comes from the lane (x0, y0) with
+			// x = y0 and y = 2*x0 + 3*y0
+			y0 := x
+			x0 := 3 * (y - 3*y0 + 15) % 5
+			l := lane(x0, y0)
+			emit("MOVD %d(%s), %s", l*8, src, b[x])
+			emit("EOR %s, %s, %s", d[x0], b[x], b[x])
+			if r[l] != 0 {
+				emit("ROR $%d, %s, %s", 64-r[l], b[x], b[x])
+			}
+		}
+		emit("// χ, row %d", y)
+		for x := 0; x < 5; x++ {
+			emit("BIC %s, %s, R21", b[(x+1)%5], b[(x+2)%5])
+			emit("EOR %s, R21, R21", b[x])
+			if x == 0 && y == 0 {
+				emit("// ι")
+				emit("MOVD.P 8(R2), R4")
+				emit("EOR R4, R21, R21")
+			}
+			emit("MOVD R21, %d(%s)", lane(x, y)*8, dst)
+		}
+	}
+}
+
+func main() {
+	out.WriteString(`// Code generated by gen_keccakf_arm64.go. DO NOT EDIT.
+
+//go:build arm64 && !appengine && !gccgo
+// +build arm64,!appengine,!gccgo
+
+#include "textflag.h"
+
+// func keccakF1600Scalar(a, t *[25]uint64, nr int)
+TEXT ·keccakF1600Scalar(SB), NOSPLIT, $0-24
+`)
+	emit("MOVD a+0(FP), R0")
+	emit("MOVD t+8(FP), R1")
+	emit("MOVD nr+16(FP), R3")
+	emit("// start at the round constant 24-nr")
+	emit("MOVD $·rc(SB), R2")
+	emit("MOVD $24, R4")
+	emit("SUB R3, R4, R4")
+	emit("ADD R4<<3, R2, R2")
+	emit("LSR $1, R3, R3")
+	emit("CBZ R3, done")
+	out.WriteString("\nloop:\n")
+	round("R0", "R1")
+	round("R1", "R0")
+	emit("SUBS $1, R3, R3")
+	emit("BNE loop")
+	out.WriteString("\ndone:\n")
+	emit("RET")
+
+	if err := ioutil.WriteFile("keccakf_arm64_scalar.s", out.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,128 @@
+//go:build ignore
+// +build ignore
+
+// This program generates keccakf_x4_amd64.s, the AVX2 implementation of
+// keccakF1600x4. Run it with
+//
+//	go generate
+//
+// The four states are interleaved: lane i of state j is a[i][j], so that
+// lane i of the four states is one YMM register. The 25 lanes do not fit in
+// the 16 registers, so the rounds alternate between `a` and a copy on the
+// stack, and the number of rounds must be even.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+)
+
+var out bytes.Buffer
+
+func emit(format string, args ...interface{}) {
+	fmt.Fprintf(&out, "\t"+format+"\n", args...)
+}
+
+// lane returns the index of the lane (x, y).
+func lane(x, y int) int {
+	return x%5 + 5*(y%5)
+}
+
+// rho returns the offsets of the ρ step, indexed by lane.
+func rho() [25]int {
+	var r [25]int
+	x, y := 1, 0
+	for t := 0; t < 24; t++ {
+		r[lane(x, y)] = (t + 1) * (t + 2) / 2 % 64
+		x, y = y, (2*x+3*y)%5
+	}
+	return r
+}
+
+// round emits a round from the lanes at `src` to the lanes at `dst`. The
+// round constant is read from (SI), which is then advanced.
+//
+// Registers: Y0-Y4 hold the column parities, Y5-Y9 the θ effect of each
+// column, Y10-Y14 a row after ρ and π, and Y15 is a temporary.
+func round(src, dst string) {
+	r := rho()
+
+	emit("// θ")
+	for x := 0; x < 5; x++ {
+		emit("VMOVDQU %d%s, Y%d", lane(x, 0)*32, src, x)
+		for y := 1; y < 5; y++ {
+			emit("VPXOR %d%s, Y%d, Y%d", lane(x, y)*32, src, x, x)
+		}
+	}
+	for x := 0; x < 5; x++ {
+		d := 5 + x
+		emit("VPSLLQ $1, Y%d, Y%d", (x+1)%5, d)
+		emit("VPSRLQ $63, Y%d, Y15", (x+1)%5)
+		emit("VPOR Y15, Y%d, Y%d", d, d)
+		emit("VPXOR Y%d, Y%d, Y%d", (x+4)%5, d, d)
+	}
+
+	for y := 0; y < 5; y++ {
+		emit("// ρ and π, row %d", y)
+		for x := 0; x < 5; x++ {
+			// the lane (x, y) comes from the lane (x0, y0) with
+			// x = y0 and y = 2*x0 + 3*y0
+			y0 := x
+			x0 := 3 * (y - 3*y0 + 15) % 5
+			b, l := 10+x, lane(x0, y0)
+			emit("VMOVDQU %d%s, Y%d", l*32, src, b)
+			emit("VPXOR Y%d, Y%d, Y%d", 5+x0, b, b)
+			if r[l] != 0 {
+				emit("VPSLLQ $%d, Y%d, Y15", r[l], b)
+				emit("VPSRLQ $%d, Y%d, Y%d", 64-r[l], b, b)
+				emit("VPOR Y15, Y%d, Y%d", b, b)
+			}
+		}
+		emit("// χ, row %d", y)
+		for x := 0; x < 5; x++ {
+			emit("VPANDN Y%d, Y%d, Y15", 10+(x+2)%5, 10+(x+1)%5)
+			emit("VPXOR Y%d, Y15, Y15", 10+x)
+			if x == 0 && y == 0 {
+				emit("// ι")
+				emit("VPBROADCASTQ (SI), Y0")
+				emit("VPXOR Y0, Y15, Y15")
+				emit("ADDQ $8, SI")
+			}
+			emit("VMOVDQU Y15, %d%s", lane(x, y)*32, dst)
+		}
+	}
+}
+
+func main() {
+	out.WriteString(`// Code generated by gen_keccakf_x4.go. DO NOT EDIT.
+
+//go:build amd64 && !appengine && !gccgo
+// +build amd64,!appengine,!gccgo
+
+// func keccakF1600x4AVX2(a *[25][4]uint64, nr int)
+TEXT ·keccakF1600x4AVX2(SB), 0, $800-16
+`)
+	emit("MOVQ a+0(FP), DI")
+	emit("MOVQ nr+8(FP), CX")
+	emit("// start at the round constant 24-nr")
+	emit("MOVQ $24, AX")
+	emit("SUBQ CX, AX")
+	emit("LEAQ ·rc(SB), SI")
+	emit("LEAQ (SI)(AX*8), SI")
+	emit("SHRQ $1, CX")
+	emit("JZ done")
+	out.WriteString("\nloop:\n")
+	round("(DI)", "(SP)")
+	round("(SP)", "(DI)")
+	emit("DECQ CX")
+	emit("JNZ loop")
+	out.WriteString("\ndone:\n")
+	emit("VZEROUPPER")
+	emit("RET")
+
+	if err := ioutil.WriteFile("keccakf_x4_amd64.s", out.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
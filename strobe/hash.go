@@ -0,0 +1,134 @@
+package strobe
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash"
+)
+
+// digest is a hash.Hash that absorbs everything written to it in a single
+// AD operation, and produces its sum with PRF.
+type digest struct {
+	init    Strobe // state right after initialization, used by Reset
+	s       Strobe
+	size    int
+	started bool // set once the AD operation has begun
+}
+
+// NewHash returns a hash.Hash computing `size`-byte sums with a Strobe state
+// initialized with `customization` and `security` (see InitStrobe). The
+// returned value also implements hash.Hash64, encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler. It panics if the security target or the size
+// are invalid.
+func NewHash(customization string, security, size int) hash.Hash {
+	if size <= 0 {
+		panic("strobe: hash size must be positive")
+	}
+	s := InitStrobe(customization, security)
+	return &digest{init: s, s: s, size: size}
+}
+
+// NewHash64 is like NewHash for 8-byte sums.
+func NewHash64(customization string, security int) hash.Hash64 {
+	return NewHash(customization, security, 8).(*digest)
+}
+
+// Write streams `p` into the AD operation. It never returns an error.
+func (d *digest) Write(p []byte) (int, error) {
+	if _, err := d.s.operate(flag(OpAD), nil, p, 0, d.started); err != nil {
+		panic(err)
+	}
+	d.started = true
+	return len(p), nil
+}
+
+// Sum appends the current hash to `b`. It does not change the underlying
+// state, so more data can be written afterwards.
+func (d *digest) Sum(b []byte) []byte {
+	s := d.s
+	if !d.started {
+		s.mustOperate(flag(OpAD), nil, nil, 0)
+	}
+	ret, out := sliceForAppend(b, d.size)
+	s.mustOperate(flag(OpPRF), out, nil, d.size)
+	return ret
+}
+
+// Sum64 returns the first 8 bytes of the hash as a big-endian integer,
+// whatever the size of the hash is.
+func (d *digest) Sum64() uint64 {
+	s := d.s
+	if !d.started {
+		s.mustOperate(flag(OpAD), nil, nil, 0)
+	}
+	var out [8]byte
+	s.mustOperate(flag(OpPRF), out[:], nil, len(out))
+	return binary.BigEndian.Uint64(out[:])
+}
+
+// Reset returns the hash to the state right after initialization.
+func (d *digest) Reset() {
+	d.s = d.init
+	d.started = false
+}
+
+// Size returns the size of the sums.
+func (d *digest) Size() int {
+	return d.size
+}
+
+// BlockSize returns the number of bytes absorbed per permutation.
+func (d *digest) BlockSize() int {
+	return d.s.StrobeR
+}
+
+//
+// Checkpointing
+//
+// [magic(len(hashMagic))|size(4)|started(1)|initial state|current state]
+// where the states are serialized with Serialize.
+//
+
+const hashMagic = "strobe-hash\x01"
+
+var errInvalidHashState = errors.New("strobe: invalid hash state")
+
+// MarshalBinary serializes the hash so that it can be resumed later.
+func (d *digest) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 0, len(hashMagic)+4+1+2*serializedLength)
+	b = append(b, hashMagic...)
+	b = append(b, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b[len(hashMagic):], uint32(d.size))
+	if d.started {
+		b = append(b, 1)
+	} else {
+		b = append(b, 0)
+	}
+	b = append(b, d.init.Serialize()...)
+	b = append(b, d.s.Serialize()...)
+	return b, nil
+}
+
+// UnmarshalBinary restores a hash serialized with MarshalBinary.
+func (d *digest) UnmarshalBinary(b []byte) error {
+	if len(b) != len(hashMagic)+4+1+2*serializedLength || string(b[:len(hashMagic)]) != hashMagic {
+		return errInvalidHashState
+	}
+	b = b[len(hashMagic):]
+	size := binary.BigEndian.Uint32(b)
+	if size == 0 || size > 1<<30 || b[4] > 1 {
+		return errInvalidHashState
+	}
+	started := b[4] == 1
+	b = b[5:]
+	init, err := TryRecoverState(b[:serializedLength])
+	if err != nil {
+		return err
+	}
+	s, err := TryRecoverState(b[serializedLength:])
+	if err != nil {
+		return err
+	}
+	d.init, d.s, d.size, d.started = init, s, int(size), started
+	return nil
+}
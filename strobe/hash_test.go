@@ -0,0 +1,76 @@
+package strobe
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"hash"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+
+	for _, security := range []int{128, 256} {
+		s := InitStrobe("myHash", security)
+		s.AD(false, data)
+		expected := s.PRF(32)
+
+		h := NewHash("myHash", security, 32)
+		h.Write(data[:3])
+		h.Write(data[3:500])
+		h.Sum(nil) // does not modify the state
+		h.Write(data[500:])
+		if !bytes.Equal(h.Sum(nil), expected) {
+			t.Fatal("hash does not match AD followed by PRF")
+		}
+		if !bytes.Equal(h.Sum([]byte("prefix"))[6:], expected) {
+			t.Fatal("Sum is not idempotent")
+		}
+		if binary.BigEndian.Uint64(expected) != h.(hash.Hash64).Sum64() {
+			t.Fatal("Sum64 does not match Sum")
+		}
+
+		// the empty message
+		h.Reset()
+		empty := h.Sum(nil)
+		h.Write(nil)
+		if !bytes.Equal(h.Sum(nil), empty) {
+			t.Fatal("writing nothing changes the hash")
+		}
+		s = InitStrobe("myHash", security)
+		s.AD(false, nil)
+		if !bytes.Equal(s.PRF(32), empty) {
+			t.Fatal("Reset does not return to the initial state")
+		}
+
+		// checkpointing
+		h.Reset()
+		h.Write(data[:500])
+		checkpoint, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		restored := NewHash("", 128, 1)
+		if err := restored.(encoding.BinaryUnmarshaler).UnmarshalBinary(checkpoint); err != nil {
+			t.Fatal(err)
+		}
+		restored.Write(data[500:])
+		if !bytes.Equal(restored.Sum(nil), expected) {
+			t.Fatal("restored hash does not match")
+		}
+		restored.Reset()
+		if !bytes.Equal(restored.Sum(nil), empty) {
+			t.Fatal("restored hash does not reset to its initial state")
+		}
+		if err := restored.(encoding.BinaryUnmarshaler).UnmarshalBinary(checkpoint[1:]); err == nil {
+			t.Fatal("invalid checkpoint accepted")
+		}
+	}
+
+	h64 := NewHash64("myHash", 128)
+	h64.Write(message)
+	if h64.Size() != 8 || binary.BigEndian.Uint64(h64.Sum(nil)) != h64.Sum64() {
+		t.Fatal("invalid hash.Hash64")
+	}
+}
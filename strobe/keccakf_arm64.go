@@ -0,0 +1,49 @@
+//go:build arm64 && !appengine && !gccgo
+// +build arm64,!appengine,!gccgo
+
+package strobe
+
+import "sync"
+
+//go:generate go run gen_keccakf_arm64.go
+
+// useSHA3 is true if the CPU has the Armv8.2 SHA3 extension (EOR3, RAX1, XAR
+// and BCAX). Without it, keccakF1600 runs scalar code, which folds the
+// rotations of θ into the shifted operands of EOR and the χ step into BIC.
+// The two 64-bit lanes of a NEON register do not help a single state.
+// It is set by detectSHA3.
+var (
+	useSHA3  bool
+	sha3Once sync.Once
+)
+
+// detectSHA3 sets useSHA3. It runs on the first call to keccakF1600 rather
+// than at init, so that importing the package does not read
+// /proc/self/auxv.
+func detectSHA3() {
+	useSHA3 = hasSHA3()
+}
+
+// keccakF1600 applies the last `nr` rounds of Keccak-f[1600] to `a`.
+func keccakF1600(a *[25]uint64, nr int) {
+	sha3Once.Do(detectSHA3)
+	if useSHA3 {
+		keccakF1600SHA3(a, nr)
+	} else {
+		var t [25]uint64
+		keccakF1600Scalar(a, &t, nr)
+	}
+}
+
+// This function is implemented in keccakf_arm64.s.
+
+//go:noescape
+
+func keccakF1600SHA3(a *[25]uint64, nr int)
+
+// This function is implemented in keccakf_arm64_scalar.s, generated by
+// gen_keccakf_arm64.go. The rounds alternate between `a` and `t`.
+
+//go:noescape
+
+func keccakF1600Scalar(a, t *[25]uint64, nr int)
@@ -0,0 +1,36 @@
+//go:build arm64 && !appengine && !gccgo
+// +build arm64,!appengine,!gccgo
+
+package strobe
+
+import (
+	"testing"
+)
+
+func TestKeccakF1600SHA3(t *testing.T) {
+	sha3Once.Do(detectSHA3)
+	if !useSHA3 {
+		t.Skip("the CPU does not have the SHA3 extension")
+	}
+	keccakF1600Differential(t, keccakF1600SHA3)
+}
+
+func TestKeccakF1600Scalar(t *testing.T) {
+	keccakF1600Differential(t, func(a *[25]uint64, nr int) {
+		var t [25]uint64
+		keccakF1600Scalar(a, &t, nr)
+	})
+}
+
+func TestKeccakF1600Fallback(t *testing.T) {
+	sha3Once.Do(detectSHA3)
+	defer func(use bool) { useSHA3 = use }(useSHA3)
+	useSHA3 = false
+	keccakF1600Differential(t, keccakF1600)
+
+	var a [25]uint64
+	keccakF1600(&a, 24)
+	if a[0] != 0xF1258F7940E1DDE7 || a[24] != 0xEAF1FF7B5CECA249 {
+		t.Fatal("invalid keccakF1600")
+	}
+}
@@ -0,0 +1,9 @@
+//go:build (!amd64 && !arm64) || appengine || gccgo
+// +build !amd64,!arm64 appengine gccgo
+
+package strobe
+
+// keccakF1600 applies the last `nr` rounds of Keccak-f[1600] to `a`.
+func keccakF1600(a *[25]uint64, nr int) {
+	keccakF1600Generic(a, nr)
+}
@@ -0,0 +1,132 @@
+package strobe
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestKeccakP1600(t *testing.T) {
+	// known answer for Keccak-f[1600] on the zero state
+	var a [25]uint64
+	keccakP(&a, 64, 24)
+	if a[0] != 0xF1258F7940E1DDE7 || a[24] != 0xEAF1FF7B5CECA249 {
+		t.Fatal("invalid keccakP")
+	}
+
+	for nr := 4; nr <= 24; nr += 4 {
+		var expected, actual [25]uint64
+		for i := range expected {
+			expected[i] = uint64(i) * 0x0123456789abcdef
+		}
+		actual = expected
+		for i := 0; i < 3; i++ {
+			keccakP(&expected, 64, nr)
+			keccakF1600(&actual, nr)
+		}
+		if expected != actual {
+			t.Fatalf("keccakF1600 with %d rounds differs from keccakP", nr)
+		}
+	}
+}
+
+func TestKeccakPKnownAnswers(t *testing.T) {
+	// Keccak-f[b] on the zero state, from the KeccakF-200 and KeccakF-800
+	// intermediate values of the Keccak team (XKCP). Only a prefix of the
+	// state is checked for Keccak-f[800].
+	for _, kat := range []struct {
+		width    int
+		rounds   int
+		expected string
+	}{
+		{200, 18, "3c2826841cb35c171eaae9b811134ceaa3852c69d2c5abafea"},
+		{800, 22, "5dd431e5fbc604f499bfa0232f45f8f1"},
+	} {
+		p, err := NewKeccakP(kat.width, kat.rounds)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected, _ := hex.DecodeString(kat.expected)
+		state := make([]byte, p.Size())
+		p.Permute(state)
+		if !bytes.Equal(state[:len(expected)], expected) {
+			t.Fatalf("invalid Keccak-f[%d]: %x", kat.width, state)
+		}
+	}
+}
+
+func TestKeccakPLanes(t *testing.T) {
+	for _, w := range []uint{8, 16, 32} {
+		var a [25]uint64
+		for i := range a {
+			a[i] = uint64(i) * 0x0123456789abcdef & (1<<w - 1)
+		}
+		keccakP(&a, w, keccakRounds(w))
+		for _, lane := range a {
+			if lane>>w != 0 {
+				t.Fatalf("a lane of Keccak-f[%d] is larger than %d bits", 25*w, w)
+			}
+		}
+	}
+}
+
+// keccakF1600Differential compares `f` to keccakF1600Generic for every
+// supported number of rounds, on several successive states.
+func keccakF1600Differential(t *testing.T, f func(*[25]uint64, int)) {
+	for nr := 4; nr <= 24; nr += 4 {
+		var expected, actual [25]uint64
+		for i := range expected {
+			expected[i] = uint64(i+nr) * 0xfedcba9876543210
+		}
+		actual = expected
+		for i := 0; i < 8; i++ {
+			keccakF1600Generic(&expected, nr)
+			f(&actual, nr)
+			if expected != actual {
+				t.Fatalf("Keccak-p[1600,%d] differs from the generic code after %d calls", nr, i+1)
+			}
+		}
+	}
+}
+
+func TestKeccakF1600Generic(t *testing.T) {
+	keccakF1600Differential(t, keccakF1600)
+}
+
+func BenchmarkKeccakF1600(b *testing.B) {
+	var a [25]uint64
+	for i := 0; i < b.N; i++ {
+		keccakF1600(&a, 24)
+	}
+}
+
+func TestKeccakF1600x4(t *testing.T) {
+	for nr := 4; nr <= 24; nr += 4 {
+		var a [25][4]uint64
+		var expected [4][25]uint64
+		for i := range a {
+			for j := range a[i] {
+				a[i][j] = uint64(i+25*j+nr) * 0x9e3779b97f4a7c15
+				expected[j][i] = a[i][j]
+			}
+		}
+		for call := 0; call < 3; call++ {
+			keccakF1600x4(&a, nr)
+			for j := range expected {
+				keccakF1600Generic(&expected[j], nr)
+				for i := range expected[j] {
+					if a[i][j] != expected[j][i] {
+						t.Fatalf("state %d of Keccak-p[1600,%d] x4 differs from the generic code after %d calls", j, nr, call+1)
+					}
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkKeccakF1600x4(b *testing.B) {
+	var a [25][4]uint64
+	for i := 0; i < b.N; i++ {
+		keccakF1600x4(&a, 24)
+	}
+}
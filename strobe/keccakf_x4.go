@@ -0,0 +1,16 @@
+package strobe
+
+// keccakF1600x4Generic applies the last `nr` rounds of Keccak-f[1600] to four
+// interleaved states, lane i of state j being a[i][j], one at a time.
+func keccakF1600x4Generic(a *[25][4]uint64, nr int) {
+	var state [25]uint64
+	for j := 0; j < 4; j++ {
+		for i := range state {
+			state[i] = a[i][j]
+		}
+		keccakF1600(&state, nr)
+		for i := range state {
+			a[i][j] = state[i]
+		}
+	}
+}
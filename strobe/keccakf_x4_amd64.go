@@ -0,0 +1,27 @@
+//go:build amd64 && !appengine && !gccgo
+// +build amd64,!appengine,!gccgo
+
+package strobe
+
+//go:generate go run gen_keccakf_x4.go
+
+// useAVX2 is true if the CPU and the OS support AVX2.
+var useAVX2 = hasAVX2()
+
+// keccakF1600x4 applies the last `nr` rounds of Keccak-f[1600] to four
+// interleaved states, lane i of state j being a[i][j]. `nr` must be a
+// multiple of 4, as for keccakF1600.
+func keccakF1600x4(a *[25][4]uint64, nr int) {
+	if useAVX2 {
+		keccakF1600x4AVX2(a, nr)
+	} else {
+		keccakF1600x4Generic(a, nr)
+	}
+}
+
+// This function is implemented in keccakf_x4_amd64.s, generated by
+// gen_keccakf_x4.go.
+
+//go:noescape
+
+func keccakF1600x4AVX2(a *[25][4]uint64, nr int)
@@ -0,0 +1,8 @@
+//go:build !amd64 || appengine || gccgo
+// +build !amd64 appengine gccgo
+
+package strobe
+
+func keccakF1600x4(a *[25][4]uint64, nr int) {
+	keccakF1600x4Generic(a, nr)
+}
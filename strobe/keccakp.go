@@ -0,0 +1,96 @@
+package strobe
+
+// This file implements Keccak-p[b, nr] for the widths b = 25*w smaller than
+// 1600 (w = 8, 16 or 32 bits per lane), for Strobe-lite. Keccak-f[1600] has
+// optimized implementations in keccakf.go and keccakf_amd64.s.
+//
+// Keccak-f[200] and Keccak-f[800] are checked against the intermediate values
+// of the Keccak team, but no transcript of the reference C implementation of
+// Strobe-lite was available to check the small-width Strobe states against.
+
+// rc stores the round constants for use in the ι step.
+var rc = [24]uint64{
+	0x0000000000000001,
+	0x0000000000008082,
+	0x800000000000808A,
+	0x8000000080008000,
+	0x000000000000808B,
+	0x0000000080000001,
+	0x8000000080008081,
+	0x8000000000008009,
+	0x000000000000008A,
+	0x0000000000000088,
+	0x0000000080008009,
+	0x000000008000000A,
+	0x000000008000808B,
+	0x800000000000008B,
+	0x8000000000008089,
+	0x8000000000008003,
+	0x8000000000008002,
+	0x8000000000000080,
+	0x000000000000800A,
+	0x800000008000000A,
+	0x8000000080008081,
+	0x8000000000008080,
+	0x0000000080000001,
+	0x8000000080008008,
+}
+
+// rotations stores the offsets of the ρ step, in the order of the π step.
+var rotations = [24]uint{1, 3, 6, 10, 15, 21, 28, 36, 45, 55, 2, 14, 27, 41, 56, 8, 25, 43, 62, 18, 39, 61, 20, 44}
+
+// piLanes stores the lanes visited by the π step.
+var piLanes = [24]int{10, 7, 11, 17, 18, 3, 5, 16, 8, 21, 24, 4, 15, 23, 19, 13, 12, 2, 20, 14, 22, 9, 6, 1}
+
+// keccakRounds returns the number of rounds of Keccak-f[25*w], 12 + 2*log2(w).
+func keccakRounds(w uint) int {
+	rounds := 12
+	for ; w > 1; w >>= 1 {
+		rounds += 2
+	}
+	return rounds
+}
+
+// keccakP applies Keccak-p[25*w, nr], the last nr rounds of Keccak-f[25*w],
+// to a state represented as 25 w-bit lanes stored in uint64s. It is a
+// straightforward implementation: the small widths are for interoperability
+// with constrained devices, not for speed.
+func keccakP(a *[25]uint64, w uint, nr int) {
+	mask := uint64(1)<<w - 1
+	if w == 64 {
+		mask = ^uint64(0)
+	}
+	rotl := func(x uint64, n uint) uint64 {
+		n %= w
+		return (x<<n | x>>(w-n)) & mask
+	}
+
+	var c [5]uint64
+	last := keccakRounds(w)
+	for round := last - nr; round < last; round++ {
+		// θ
+		for x := 0; x < 5; x++ {
+			c[x] = a[x] ^ a[x+5] ^ a[x+10] ^ a[x+15] ^ a[x+20]
+		}
+		for x := 0; x < 5; x++ {
+			d := c[(x+4)%5] ^ rotl(c[(x+1)%5], 1)
+			for y := 0; y < 25; y += 5 {
+				a[y+x] ^= d
+			}
+		}
+		// ρ and π
+		t := a[1]
+		for i, lane := range piLanes {
+			t, a[lane] = a[lane], rotl(t, rotations[i])
+		}
+		// χ
+		for y := 0; y < 25; y += 5 {
+			copy(c[:], a[y:y+5])
+			for x := 0; x < 5; x++ {
+				a[y+x] = c[x] ^ ^c[(x+1)%5]&c[(x+2)%5]&mask
+			}
+		}
+		// ι
+		a[0] ^= rc[round] & mask
+	}
+}
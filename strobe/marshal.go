@@ -0,0 +1,142 @@
+package strobe
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+//
+// Binary encoding
+//
+// MarshalBinary and UnmarshalBinary implement encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler with a versioned format, which unlike Serialize
+// records every setting of the state:
+//
+//	magic         "STRB"
+//	version       1
+//	permutation   1 for Keccak-p, 2 for Xoodoo
+//	width         permutation width in bits (2 bytes)
+//	rounds        number of rounds of the permutation
+//	security      64, 128 or 256 (2 bytes)
+//	flags         1 if initialized, | 2 in abort on failure mode
+//	role          see Role
+//	curFlags      the current operation
+//	posBegin      start of the current operation + 1 (0 := previous block)
+//	pos           position in the rate
+//	minMACLength  see SetMinMACLength (4 bytes)
+//	state         width/8 bytes
+//
+// Multi-byte integers are big-endian.
+//
+
+const (
+	marshalMagic   = "STRB"
+	marshalVersion = 1
+
+	marshalKeccak = 1
+	marshalXoodoo = 2
+
+	// marshalHeaderLength is the length of the encoding before the state.
+	marshalHeaderLength = len(marshalMagic) + 16
+)
+
+// MarshalBinary encodes the state. The tracer and the protocol of the state
+// (see SetTracer and SetProtocol) are not encoded. It returns
+// ErrStatePoisoned if the state is poisoned, ErrStreamInProgress if an
+// operation is being streamed, and ErrNotSerializable if it runs on a
+// permutation other than Keccak-p or Xoodoo[12].
+func (s Strobe) MarshalBinary() ([]byte, error) {
+	if s.poisoned {
+		return nil, ErrStatePoisoned
+	}
+	if s.locked {
+		return nil, ErrStreamInProgress
+	}
+	var id, rounds byte
+	switch p := s.perm.(type) {
+	case keccakPermutation:
+		id, rounds = marshalKeccak, byte(p.rounds)
+	case xoodooPermutation:
+		id, rounds = marshalXoodoo, byte(len(xoodooRoundConstants))
+	default:
+		return nil, ErrNotSerializable
+	}
+	if s.MinMACLength() > 1<<31-1 {
+		return nil, fmt.Errorf("strobe: cannot encode a minimum MAC length of %d", s.MinMACLength())
+	}
+	size := s.perm.Size()
+
+	b := make([]byte, marshalHeaderLength+size)
+	copy(b, marshalMagic)
+	h := b[len(marshalMagic):]
+	h[0] = marshalVersion
+	h[1] = id
+	binary.BigEndian.PutUint16(h[2:], uint16(size*8))
+	h[4] = rounds
+	binary.BigEndian.PutUint16(h[5:], uint16((size-s.duplexRate)*4))
+	if s.initialized {
+		h[7] |= 1
+	}
+	if s.abortOnFailure {
+		h[7] |= 2
+	}
+	h[8] = byte(s.I0)
+	h[9] = byte(s.curFlags)
+	h[10] = s.posBegin
+	h[11] = byte(s.pos)
+	binary.BigEndian.PutUint32(h[12:], uint32(s.MinMACLength()))
+	copy(h[16:], s.state[:size])
+	return b, nil
+}
+
+// UnmarshalBinary decodes a state encoded by MarshalBinary and replaces `s`
+// with it, without a tracer or a protocol. It returns an error wrapping
+// ErrInvalidState, and leaves `s` untouched, if the encoding is invalid.
+func (s *Strobe) UnmarshalBinary(data []byte) error {
+	if len(data) < marshalHeaderLength || string(data[:len(marshalMagic)]) != marshalMagic {
+		return fmt.Errorf("%w: not an encoded state", ErrInvalidState)
+	}
+	data = data[len(marshalMagic):]
+	if data[0] != marshalVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidState, data[0])
+	}
+
+	// permutation? + security?
+	width := int(binary.BigEndian.Uint16(data[2:]))
+	rounds := int(data[4])
+	config := Config{Security: int(binary.BigEndian.Uint16(data[5:]))}
+	switch data[1] {
+	case marshalKeccak:
+		config.Width, config.Rounds = width, rounds
+		if config.Width == 0 || config.Rounds == 0 {
+			return fmt.Errorf("%w: invalid permutation", ErrInvalidState)
+		}
+	case marshalXoodoo:
+		if width != xoodooSize*8 || rounds != len(xoodooRoundConstants) {
+			return fmt.Errorf("%w: invalid permutation", ErrInvalidState)
+		}
+		config.Permutation = xoodooPermutation{}
+	default:
+		return fmt.Errorf("%w: unknown permutation %d", ErrInvalidState, data[1])
+	}
+	if config.Security == 0 {
+		return fmt.Errorf("%w: invalid security", ErrInvalidState)
+	}
+	if err := config.check(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidState, err)
+	}
+	var t Strobe
+	t.configure(config)
+	if len(data) != marshalHeaderLength-len(marshalMagic)+t.perm.Size() {
+		return fmt.Errorf("%w: invalid length", ErrInvalidState)
+	}
+
+	// settings + positions + state
+	minMACLength := binary.BigEndian.Uint32(data[12:])
+	if err := t.restore(data[7], data[8], data[9], data[10], data[11], minMACLength, data[16:]); err != nil {
+		return err
+	}
+
+	*s = t
+	return nil
+}
@@ -0,0 +1,119 @@
+package strobe
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"testing"
+)
+
+var (
+	_ encoding.BinaryMarshaler   = Strobe{}
+	_ encoding.BinaryUnmarshaler = &Strobe{}
+)
+
+// marshalled returns a keyed state in the middle of a streamed operation,
+// with non-default settings, and its encoding.
+func marshalled(t *testing.T, config Config) (Strobe, []byte) {
+	s, err := TryInitStrobeWithConfig("marshal", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SetAbortOnFailure(true)
+	s.SetMinMACLength(300)
+	s.KEY([]byte("key"))
+	s.Send_ENC_unauthenticated(false, message)
+	s.Operate(false, "AD", message[:3], 0, false)
+	data, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, data
+}
+
+func TestMarshalBinary(t *testing.T) {
+	configs := []Config{
+		{Security: 128},
+		{Security: 256},
+		{Security: 128, Rounds: 12},
+		{Security: 256, Width: 800},
+		{Security: 64, Width: 200},
+		{Security: 128, Permutation: NewXoodoo()},
+	}
+	for _, config := range configs {
+		s1, data := marshalled(t, config)
+		var s2 Strobe
+		if err := s2.UnmarshalBinary(data); err != nil {
+			t.Fatal(err)
+		}
+		if s2.debugPrintState() != s1.debugPrintState() || s2.duplexRate != s1.duplexRate ||
+			s2.perm.Name() != s1.perm.Name() || s2.Role() != Initiator ||
+			s2.MinMACLength() != 300 || !s2.abortOnFailure {
+			t.Fatalf("%+v: a state is not decoded as it was encoded", config)
+		}
+		if again, _ := s2.MarshalBinary(); !bytes.Equal(again, data) {
+			t.Fatalf("%+v: a decoded state is not encoded as it was", config)
+		}
+
+		// the decoded state continues the streamed operation
+		s1.Operate(false, "AD", message[3:], 0, true)
+		s2.Operate(false, "AD", message[3:], 0, true)
+		if !bytes.Equal(s1.PRF(32), s2.PRF(32)) {
+			t.Fatalf("%+v: a decoded state does not behave like the original", config)
+		}
+	}
+}
+
+func TestUnmarshalBinaryErrors(t *testing.T) {
+	_, data := marshalled(t, Config{Security: 128})
+	invalid := map[string]func(b []byte) []byte{
+		"empty":                 func(b []byte) []byte { return nil },
+		"magic":                 func(b []byte) []byte { b[0] = 's'; return b },
+		"version":               func(b []byte) []byte { b[4] = 2; return b },
+		"permutation":           func(b []byte) []byte { b[5] = 3; return b },
+		"xoodoo width":          func(b []byte) []byte { b[5] = marshalXoodoo; return b },
+		"width":                 func(b []byte) []byte { b[7] = 0x41; return b },
+		"rounds":                func(b []byte) []byte { b[8] = 23; return b },
+		"no rounds":             func(b []byte) []byte { b[8] = 0; return b },
+		"security":              func(b []byte) []byte { b[10] = 192; return b },
+		"no security":           func(b []byte) []byte { b[9], b[10] = 0, 0; return b },
+		"flags":                 func(b []byte) []byte { b[11] = 4; return b },
+		"role":                  func(b []byte) []byte { b[12] = byte(Symmetric) + 1; return b },
+		"current operation":     func(b []byte) []byte { b[13] = byte(flagI); return b },
+		"posBegin after pos":    func(b []byte) []byte { b[14] = b[15]; return b },
+		"pos after the rate":    func(b []byte) []byte { b[15] = 200; return b },
+		"minimum MAC length":    func(b []byte) []byte { b[16], b[17], b[18], b[19] = 0, 0, 0, 0; return b },
+		"huge MAC length":       func(b []byte) []byte { b[16] = 0x80; return b },
+		"truncated":             func(b []byte) []byte { return b[:len(b)-1] },
+		"trailing data":         func(b []byte) []byte { return append(b, 0) },
+		"truncated header":      func(b []byte) []byte { return b[:marshalHeaderLength-1] },
+		"state of another size": func(b []byte) []byte { b[6], b[7], b[8] = 0x03, 0x20, 22; return b },
+	}
+	for name, modify := range invalid {
+		var s Strobe
+		s.pos = 1
+		if err := s.UnmarshalBinary(modify(append([]byte{}, data...))); !errors.Is(err, ErrInvalidState) {
+			t.Fatalf("%s: an invalid encoding is decoded (%v)", name, err)
+		}
+		if s.pos != 1 || s.perm != nil {
+			t.Fatalf("%s: a failed decoding modified the state", name)
+		}
+	}
+
+	// a state that cannot be encoded
+	var calls int
+	keccak, _ := NewKeccakP(1600, 24)
+	s, err := TryInitStrobeWithConfig("marshal", Config{Security: 128, Permutation: countingPermutation{keccak, "counting", &calls}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.MarshalBinary(); err != ErrNotSerializable {
+		t.Fatal("a state on a custom permutation should not be encoded")
+	}
+	s = InitStrobe("marshal", 128)
+	s.SetAbortOnFailure(true)
+	s.Recv_MAC(false, make([]byte, 16))
+	if _, err := s.MarshalBinary(); err != ErrStatePoisoned {
+		t.Fatal("a poisoned state should not be encoded")
+	}
+}
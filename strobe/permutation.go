@@ -0,0 +1,136 @@
+package strobe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+//
+// Permutations
+//
+// A Strobe state runs on a Permutation, Keccak-f[1600] by default. Other
+// Keccak-p permutations are selected with the Width and Rounds of a Config,
+// and any other permutation with its Permutation field.
+//
+
+var (
+	// ErrInvalidPermutation is returned when a Config has an unsupported
+	// Permutation, or sets both a Permutation and a Width or Rounds.
+	ErrInvalidPermutation = errors.New("strobe: invalid permutation")
+	// ErrNotSerializable is the panic of Serialize for a state running on a
+	// permutation other than Keccak-p or Xoodoo[12], which cannot be recorded.
+	ErrNotSerializable = errors.New("strobe: cannot serialize a state running on a custom permutation")
+)
+
+// maxStateSize is the largest Permutation.Size supported, that of Keccak-f[1600].
+const maxStateSize = 1600 / 8
+
+// Permutation is a cryptographic permutation on which a Strobe state runs,
+// see Config. It is shared by the copies of a Strobe state, so it must be
+// safe to call Permute from several goroutines if copies are used
+// concurrently.
+type Permutation interface {
+	// Size returns the width of the permutation in bytes, at most 200.
+	Size() int
+	// Permute applies the permutation in place to a Size()-byte state.
+	Permute(state []byte)
+	// Name returns the name of the permutation, absorbed in the domain string
+	// at initialization. Only Keccak-f[b] uses the standard domain string,
+	// so a Permutation named "Keccak-f[1600]" (for example one counting the
+	// calls to another) must compute Keccak-f[1600].
+	Name() string
+}
+
+// keccakPermutation is Keccak-p[width, rounds] on a little-endian state.
+type keccakPermutation struct {
+	width  int // in bits
+	rounds int
+}
+
+// NewKeccakP returns Keccak-p[width, rounds], the last `rounds` rounds of
+// Keccak-f[width]. It returns ErrInvalidWidth or ErrInvalidRounds for the
+// values not accepted by Config.
+func NewKeccakP(width, rounds int) (Permutation, error) {
+	switch width {
+	case 1600, 800, 400, 200:
+	default:
+		return nil, ErrInvalidWidth
+	}
+	if rounds <= 0 || rounds > keccakRounds(uint(width/25)) || width == 1600 && rounds%4 != 0 {
+		return nil, ErrInvalidRounds
+	}
+	return keccakPermutation{width: width, rounds: rounds}, nil
+}
+
+func (k keccakPermutation) Size() int {
+	return k.width / 8
+}
+
+func (k keccakPermutation) Name() string {
+	if k.rounds == keccakRounds(uint(k.width/25)) {
+		return fmt.Sprintf("Keccak-f[%d]", k.width)
+	}
+	return fmt.Sprintf("Keccak-p[%d,%d]", k.width, k.rounds)
+}
+
+func (k keccakPermutation) Permute(state []byte) {
+	var a [25]uint64
+	laneSize := k.width / 200
+	loadLanes(&a, state, laneSize)
+	if k.width == 1600 {
+		keccakF1600(&a, k.rounds)
+	} else {
+		keccakP(&a, uint(k.width/25), k.rounds)
+	}
+	storeLanes(&a, state, laneSize)
+}
+
+// permutationByName returns the Permutation called `name` that can be
+// selected in a Config, or nil. It does not know Keccak-p permutations,
+// which are selected by their width and rounds.
+func permutationByName(name string) Permutation {
+	switch name {
+	case xoodooPermutation{}.Name():
+		return xoodooPermutation{}
+	}
+	return nil
+}
+
+// isKeccakF returns true if `p` is named after Keccak-f with its width.
+func isKeccakF(p Permutation) bool {
+	return p.Name() == fmt.Sprintf("Keccak-f[%d]", p.Size()*8)
+}
+
+// loadLanes reads the lanes of `a`, which are `laneSize` bytes long, from `b`.
+func loadLanes(a *[25]uint64, b []byte, laneSize int) {
+	if laneSize == 8 {
+		for i := range a {
+			a[i] = binary.LittleEndian.Uint64(b[i*8:])
+		}
+		return
+	}
+	for i := range a {
+		var lane uint64
+		for j := laneSize - 1; j >= 0; j-- {
+			lane = lane<<8 | uint64(b[i*laneSize+j])
+		}
+		a[i] = lane
+	}
+}
+
+// storeLanes writes the lanes of `a`, which are `laneSize` bytes long, in `b`.
+func storeLanes(a *[25]uint64, b []byte, laneSize int) {
+	if laneSize == 8 {
+		for i, lane := range a {
+			binary.LittleEndian.PutUint64(b[i*8:], lane)
+		}
+		return
+	}
+	for i, lane := range a {
+		for j := 0; j < laneSize; j++ {
+			b[i*laneSize+j] = byte(lane)
+			lane >>= 8
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package strobe
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// countingPermutation counts the calls to another permutation.
+type countingPermutation struct {
+	Permutation
+	name  string
+	calls *int
+}
+
+func (c countingPermutation) Name() string {
+	return c.name
+}
+
+func (c countingPermutation) Permute(state []byte) {
+	*c.calls++
+	c.Permutation.Permute(state)
+}
+
+func TestPermutation(t *testing.T) {
+	keccak, err := NewKeccakP(1600, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keccak.Name() != "Keccak-f[1600]" || keccak.Size() != 200 {
+		t.Fatal("invalid Keccak-f[1600]")
+	}
+
+	// an instrumented Keccak-f[1600] is interoperable
+	var calls int
+	counting := countingPermutation{keccak, "Keccak-f[1600]", &calls}
+	s := InitStrobeWithConfig("myProtocol", Config{Security: 128, Permutation: counting})
+	standard := InitStrobe("myProtocol", 128)
+	calls = 0
+	s.KEY([]byte("key"))
+	standard.KEY([]byte("key"))
+	if calls != 1 {
+		t.Fatal("expected 1 permutation for KEY, got", calls)
+	}
+	if !bytes.Equal(s.PRF(32), standard.PRF(32)) {
+		t.Fatal("instrumented Keccak-f[1600] differs")
+	}
+
+	// other permutations have their own domain
+	renamed := InitStrobeWithConfig("myProtocol", Config{Security: 128, Permutation: countingPermutation{keccak, "other", &calls}})
+	if renamed.debugPrintState() == InitStrobe("myProtocol", 128).debugPrintState() {
+		t.Fatal("the name of the permutation is not absorbed")
+	}
+	func() {
+		defer func() {
+			if recover() != ErrNotSerializable {
+				t.Fatal("expected ErrNotSerializable")
+			}
+		}()
+		renamed.Serialize()
+	}()
+
+	// reduced rounds through Config or NewKeccakP
+	p12, _ := NewKeccakP(1600, 12)
+	if p12.Name() != "Keccak-p[1600,12]" {
+		t.Fatal("invalid Keccak-p[1600,12]")
+	}
+	viaConfig := InitStrobeWithConfig("myProtocol", Config{Security: 128, Rounds: 12})
+	viaPermutation := InitStrobeWithConfig("myProtocol", Config{Security: 128, Permutation: p12})
+	if viaConfig.debugPrintState() != viaPermutation.debugPrintState() {
+		t.Fatal("NewKeccakP differs from Config")
+	}
+
+	for _, config := range []Config{
+		{Security: 128, Permutation: keccak, Width: 1600},
+		{Security: 128, Permutation: keccak, Rounds: 24},
+		{Security: 128, Permutation: countingPermutation{oversized{}, "oversized", &calls}},
+	} {
+		if _, err := TryInitStrobeWithConfig("", config); err != ErrInvalidPermutation {
+			t.Fatal("expected ErrInvalidPermutation, got", err)
+		}
+	}
+	if _, err := NewKeccakP(1600, 22); !errors.Is(err, ErrInvalidRounds) {
+		t.Fatal("expected ErrInvalidRounds, got", err)
+	}
+	if _, err := NewKeccakP(100, 12); !errors.Is(err, ErrInvalidWidth) {
+		t.Fatal("expected ErrInvalidWidth, got", err)
+	}
+}
+
+type oversized struct{}
+
+func (oversized) Size() int            { return 256 }
+func (oversized) Permute(state []byte) {}
+func (oversized) Name() string         { return "oversized" }
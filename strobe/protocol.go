@@ -0,0 +1,353 @@
+package strobe
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//
+// Protocol state machines
+//
+// A Protocol describes the sequences of operations allowed on a Strobe
+// state, for example "KEY; send_AEAD*; recv_AEAD*". Once attached with
+// SetProtocol, any operation that breaks it is rejected before it touches
+// the state.
+//
+// The grammar is a regular expression over operation names:
+//
+//	AD KEY PRF send_CLR recv_CLR send_ENC recv_ENC send_MAC recv_MAC RATCHET
+//	meta_X          the meta variant of the operation X (meta_AD, ...)
+//	send_AEAD       send_ENC; AD; send_MAC (as run by Send_AEAD)
+//	recv_AEAD       recv_ENC; AD; recv_MAC (as run by Recv_AEAD)
+//	a; b            a followed by b
+//	a | b           a or b
+//	a* a+ a?        zero or more, one or more, zero or one a
+//	(a)             grouping
+//
+// Streamed operations are checked once, when they begin.
+//
+
+// ErrInvalidProtocol is wrapped by the errors of CompileProtocol.
+var ErrInvalidProtocol = errors.New("strobe: invalid protocol")
+
+// ErrProtocolViolation is wrapped by the *ProtocolError returned when an
+// operation is not allowed by the protocol attached to a Strobe state.
+var ErrProtocolViolation = errors.New("strobe: protocol violation")
+
+// ProtocolError describes an operation rejected by a Protocol.
+type ProtocolError struct {
+	Expected []string // the operations allowed instead, empty if the protocol is over
+	Actual   string   // the rejected operation
+}
+
+func (e *ProtocolError) Error() string {
+	if len(e.Expected) == 0 {
+		return fmt.Sprintf("strobe: protocol violation: expected no more operations, got %s", e.Actual)
+	}
+	return fmt.Sprintf("strobe: protocol violation: expected %s, got %s",
+		strings.Join(e.Expected, " or "), e.Actual)
+}
+
+func (e *ProtocolError) Unwrap() error {
+	return ErrProtocolViolation
+}
+
+// Protocol is a compiled protocol grammar. It is immutable and can be shared
+// by any number of Strobe states.
+type Protocol struct {
+	grammar string
+	states  []protocolState // deterministic automaton, starting at states[0]
+}
+
+type protocolState struct {
+	next   map[flag]int
+	accept bool // the protocol can end here
+}
+
+// CompileProtocol compiles a protocol grammar, see the top of protocol.go.
+func CompileProtocol(grammar string) (*Protocol, error) {
+	p := &protocolParser{input: grammar}
+	p.nfa = append(p.nfa, nfaNode{}) // placeholder for the start node
+	p.scan()
+	frag, err := p.parseAlt()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok != "" {
+		return nil, p.errorf("unexpected %q", p.tok)
+	}
+	p.nfa[0].eps = []int{frag.start}
+	return &Protocol{grammar: grammar, states: determinize(p.nfa, frag.end)}, nil
+}
+
+// MustCompileProtocol is like CompileProtocol but panics on invalid grammars.
+func MustCompileProtocol(grammar string) *Protocol {
+	p, err := CompileProtocol(grammar)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the grammar of the protocol.
+func (p *Protocol) String() string {
+	return p.grammar
+}
+
+// SetProtocol attaches `p` to the state: from now on, operations that are not
+// allowed by `p` fail with a *ProtocolError (the high-level functions panic
+// with it). Operations run before SetProtocol are not taken into account. A
+// nil Protocol removes the checks. The protocol is not serialized.
+func (s *Strobe) SetProtocol(p *Protocol) {
+	s.protocol = p
+	s.protocolState = 0
+}
+
+// ProtocolComplete returns true if the operations run since SetProtocol form
+// a complete run of the protocol, or if no protocol is attached. It can be
+// used at the end of a session to detect a missing final operation.
+func (s *Strobe) ProtocolComplete() bool {
+	return s.protocol == nil || s.protocol.states[s.protocolState].accept
+}
+
+// checkProtocol returns the state of the protocol after the operation
+// `flags`, or a *ProtocolError.
+func (s *Strobe) checkProtocol(flags flag) (int, error) {
+	state := &s.protocol.states[s.protocolState]
+	if next, ok := state.next[flags]; ok {
+		return next, nil
+	}
+	expected := make([]string, 0, len(state.next))
+	for f := range state.next {
+		expected = append(expected, flagsName(f))
+	}
+	sort.Strings(expected)
+	return 0, &ProtocolError{Expected: expected, Actual: flagsName(flags)}
+}
+
+// flagsName returns the name of the operation in the protocol grammar.
+func flagsName(f flag) string {
+	if f&flagM != 0 {
+		return "meta_" + Operation(f&^flagM).String()
+	}
+	return Operation(f).String()
+}
+
+//
+// Compilation: the grammar is parsed into a non-deterministic automaton with
+// epsilon transitions, which is then turned into a deterministic one.
+//
+
+type nfaNode struct {
+	eps  []int // epsilon transitions
+	sym  flag  // transition on the operation sym to next, if next != 0
+	next int
+}
+
+type nfaFragment struct {
+	start, end int
+}
+
+type protocolParser struct {
+	input  string
+	offset int    // offset of tok in input
+	next   int    // offset of the token after tok
+	tok    string // current token, "" at the end of the input
+	nfa    []nfaNode
+}
+
+func (p *protocolParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s at offset %d", ErrInvalidProtocol, fmt.Sprintf(format, args...), p.offset)
+}
+
+// scan reads the next token.
+func (p *protocolParser) scan() {
+	for p.next < len(p.input) && strings.IndexByte(" \t\r\n", p.input[p.next]) >= 0 {
+		p.next++
+	}
+	p.offset = p.next
+	if p.next == len(p.input) {
+		p.tok = ""
+		return
+	}
+	if strings.IndexByte(";|*+?()", p.input[p.next]) >= 0 {
+		p.next++
+	} else {
+		for p.next < len(p.input) && strings.IndexByte(" \t\r\n;|*+?()", p.input[p.next]) < 0 {
+			p.next++
+		}
+	}
+	p.tok = p.input[p.offset:p.next]
+}
+
+func (p *protocolParser) node() int {
+	p.nfa = append(p.nfa, nfaNode{})
+	return len(p.nfa) - 1
+}
+
+func (p *protocolParser) sequence(a, b nfaFragment) nfaFragment {
+	p.nfa[a.end].eps = append(p.nfa[a.end].eps, b.start)
+	return nfaFragment{a.start, b.end}
+}
+
+// parseAlt parses: seq ('|' seq)*
+func (p *protocolParser) parseAlt() (nfaFragment, error) {
+	frag, err := p.parseSeq()
+	if err != nil || p.tok != "|" {
+		return frag, err
+	}
+	start, end := p.node(), p.node()
+	p.nfa[start].eps = []int{frag.start}
+	p.nfa[frag.end].eps = append(p.nfa[frag.end].eps, end)
+	for p.tok == "|" {
+		p.scan()
+		frag, err = p.parseSeq()
+		if err != nil {
+			return frag, err
+		}
+		p.nfa[start].eps = append(p.nfa[start].eps, frag.start)
+		p.nfa[frag.end].eps = append(p.nfa[frag.end].eps, end)
+	}
+	return nfaFragment{start, end}, nil
+}
+
+// parseSeq parses: rep (';' rep)*
+func (p *protocolParser) parseSeq() (nfaFragment, error) {
+	frag, err := p.parseRep()
+	for err == nil && p.tok == ";" {
+		p.scan()
+		var next nfaFragment
+		if next, err = p.parseRep(); err == nil {
+			frag = p.sequence(frag, next)
+		}
+	}
+	return frag, err
+}
+
+// parseRep parses: atom ('*' | '+' | '?')*
+func (p *protocolParser) parseRep() (nfaFragment, error) {
+	frag, err := p.parseAtom()
+	if err != nil {
+		return frag, err
+	}
+	for p.tok == "*" || p.tok == "+" || p.tok == "?" {
+		start, end := p.node(), p.node()
+		p.nfa[start].eps = []int{frag.start}
+		p.nfa[frag.end].eps = append(p.nfa[frag.end].eps, end)
+		if p.tok != "+" { // zero times
+			p.nfa[start].eps = append(p.nfa[start].eps, end)
+		}
+		if p.tok != "?" { // more times
+			p.nfa[frag.end].eps = append(p.nfa[frag.end].eps, frag.start)
+		}
+		frag = nfaFragment{start, end}
+		p.scan()
+	}
+	return frag, nil
+}
+
+// parseAtom parses: operation | '(' alt ')'
+func (p *protocolParser) parseAtom() (nfaFragment, error) {
+	switch p.tok {
+	case "":
+		return nfaFragment{}, p.errorf("unexpected end of protocol")
+	case "(":
+		p.scan()
+		frag, err := p.parseAlt()
+		if err != nil {
+			return frag, err
+		}
+		if p.tok != ")" {
+			return frag, p.errorf("missing )")
+		}
+		p.scan()
+		return frag, nil
+	case ";", "|", "*", "+", "?", ")":
+		return nfaFragment{}, p.errorf("unexpected %q", p.tok)
+	}
+
+	var ops []flag
+	switch p.tok {
+	case "send_AEAD":
+		ops = []flag{flag(OpSendENC), flag(OpAD), flag(OpSendMAC)}
+	case "recv_AEAD":
+		ops = []flag{flag(OpRecvENC), flag(OpAD), flag(OpRecvMAC)}
+	default:
+		meta := strings.HasPrefix(p.tok, "meta_")
+		op, err := ParseOperation(strings.TrimPrefix(p.tok, "meta_"))
+		if err != nil {
+			return nfaFragment{}, p.errorf("unknown operation %q", p.tok)
+		}
+		ops = []flag{metaFlags(op, meta)}
+	}
+	p.scan()
+
+	var frag nfaFragment
+	for i, op := range ops {
+		start, end := p.node(), p.node()
+		p.nfa[start].sym, p.nfa[start].next = op, end
+		if i == 0 {
+			frag = nfaFragment{start, end}
+		} else {
+			frag = p.sequence(frag, nfaFragment{start, end})
+		}
+	}
+	return frag, nil
+}
+
+// determinize runs the subset construction on an automaton starting at node
+// 0 and accepting at node `accept`.
+func determinize(nfa []nfaNode, accept int) []protocolState {
+	// closure adds to `set` the nodes reachable from `n` by epsilon transitions
+	var closure func(set map[int]bool, n int)
+	closure = func(set map[int]bool, n int) {
+		if set[n] {
+			return
+		}
+		set[n] = true
+		for _, e := range nfa[n].eps {
+			closure(set, e)
+		}
+	}
+	key := func(set map[int]bool) string {
+		nodes := make([]int, 0, len(set))
+		for n := range set {
+			nodes = append(nodes, n)
+		}
+		sort.Ints(nodes)
+		return fmt.Sprint(nodes)
+	}
+
+	start := map[int]bool{}
+	closure(start, 0)
+	sets := []map[int]bool{start}
+	index := map[string]int{key(start): 0}
+	var states []protocolState
+	for i := 0; i < len(sets); i++ {
+		state := protocolState{next: map[flag]int{}, accept: sets[i][accept]}
+		targets := map[flag]map[int]bool{}
+		for n := range sets[i] {
+			if nfa[n].next == 0 {
+				continue
+			}
+			if targets[nfa[n].sym] == nil {
+				targets[nfa[n].sym] = map[int]bool{}
+			}
+			closure(targets[nfa[n].sym], nfa[n].next)
+		}
+		for sym, target := range targets {
+			k := key(target)
+			j, ok := index[k]
+			if !ok {
+				j = len(sets)
+				sets = append(sets, target)
+				index[k] = j
+			}
+			state.next[sym] = j
+		}
+		states = append(states, state)
+	}
+	return states
+}
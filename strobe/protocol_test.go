@@ -0,0 +1,137 @@
+package strobe
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestProtocol(t *testing.T) {
+	p := MustCompileProtocol("KEY; send_AEAD*; recv_AEAD*")
+
+	s := InitStrobe("myProtocol", 128)
+	s.SetProtocol(p)
+	if s.ProtocolComplete() {
+		t.Fatal("empty run accepted")
+	}
+	s.KEY([]byte("key"))
+	if !s.ProtocolComplete() {
+		t.Fatal("complete run not accepted")
+	}
+	s.Send_AEAD(message, nil)
+	s.Send_AEAD(message, []byte("ad"))
+
+	// the expected and actual operations are reported
+	_, err := s.TryOperateOp(false, OpKEY, []byte("key"), 0, false)
+	var protocolErr *ProtocolError
+	if !errors.As(err, &protocolErr) || !errors.Is(err, ErrProtocolViolation) {
+		t.Fatal("expected a *ProtocolError, got", err)
+	}
+	expected := []string{"recv_ENC", "send_ENC"}
+	if !reflect.DeepEqual(protocolErr.Expected, expected) || protocolErr.Actual != "KEY" {
+		t.Fatal("unexpected error", err)
+	}
+
+	// the state is left untouched
+	clone := InitStrobe("myProtocol", 128)
+	clone.KEY([]byte("key"))
+	clone.Send_AEAD(message, nil)
+	clone.Send_AEAD(message, []byte("ad"))
+	if s.debugPrintState() != clone.debugPrintState() {
+		t.Fatal("a rejected operation modified the state")
+	}
+
+	// forgetting send_MAC after send_ENC
+	s.Send_ENC_unauthenticated(false, message)
+	if s.ProtocolComplete() {
+		t.Fatal("incomplete run accepted")
+	}
+	_, err = s.TryOperateOp(false, OpRecvENC, message, 0, false)
+	if !errors.As(err, &protocolErr) || protocolErr.Actual != "recv_ENC" ||
+		!reflect.DeepEqual(protocolErr.Expected, []string{"AD"}) {
+		t.Fatal("unexpected error", err)
+	}
+	s.AD(false, nil)
+	s.Send_MAC(false, MACLEN)
+
+	// the high-level functions panic
+	func() {
+		defer func() {
+			if err, _ := recover().(error); !errors.Is(err, ErrProtocolViolation) {
+				t.Fatal("expected a protocol violation, got", err)
+			}
+		}()
+		s.KEY([]byte("key"))
+	}()
+
+	// streamed operations are checked when they begin
+	s.SetProtocol(MustCompileProtocol("meta_AD; send_CLR"))
+	w := s.ADWriter(true)
+	w.Write(message)
+	w.Write(message)
+	w.Close()
+	_, err = s.TryOperateOp(false, OpSendCLR, message, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.TryOperateOp(false, OpSendCLR, message, 0, false)
+	if !errors.As(err, &protocolErr) || len(protocolErr.Expected) != 0 {
+		t.Fatal("expected the end of the protocol, got", err)
+	}
+
+	// no protocol
+	s.SetProtocol(nil)
+	s.KEY([]byte("key"))
+	if !s.ProtocolComplete() {
+		t.Fatal("no protocol is always complete")
+	}
+}
+
+func TestCompileProtocol(t *testing.T) {
+	accepted := []struct {
+		grammar string
+		run     []flag
+		ok      bool
+	}{
+		{"AD", []flag{flag(OpAD)}, true},
+		{"AD", []flag{flag(OpAD) | flagM}, false},
+		{"meta_AD", []flag{flag(OpAD) | flagM}, true},
+		{"AD; KEY | PRF", []flag{flag(OpPRF)}, true},
+		{"AD; (KEY | PRF)", []flag{flag(OpPRF)}, false},
+		{"AD; (KEY | PRF)", []flag{flag(OpAD), flag(OpPRF)}, true},
+		{"AD+", []flag{}, false},
+		{"AD+", []flag{flag(OpAD), flag(OpAD)}, true},
+		{"AD?; KEY", []flag{flag(OpKEY)}, true},
+		{"AD?; KEY", []flag{flag(OpAD), flag(OpAD), flag(OpKEY)}, false},
+		{"(send_ENC; send_MAC)*", []flag{flag(OpSendENC), flag(OpSendMAC), flag(OpSendENC)}, false},
+		{"(send_ENC; send_MAC)*", []flag{flag(OpSendENC), flag(OpSendMAC), flag(OpSendENC), flag(OpSendMAC)}, true},
+		{"recv_AEAD", []flag{flag(OpRecvENC), flag(OpAD), flag(OpRecvMAC)}, true},
+	}
+	for _, test := range accepted {
+		p, err := CompileProtocol(test.grammar)
+		if err != nil {
+			t.Fatal(test.grammar, err)
+		}
+		state, ok := 0, true
+		for _, f := range test.run {
+			next, found := p.states[state].next[f]
+			if !found {
+				ok = false
+				break
+			}
+			state = next
+		}
+		if ok && !p.states[state].accept {
+			ok = false
+		}
+		if ok != test.ok {
+			t.Errorf("%q: run %v accepted=%v", test.grammar, test.run, ok)
+		}
+	}
+
+	for _, grammar := range []string{"", "AD;", "(AD", "AD)", "foo", "meta_foo", "*AD", "AD | ", "AD KEY"} {
+		if _, err := CompileProtocol(grammar); !errors.Is(err, ErrInvalidProtocol) {
+			t.Errorf("%q: expected ErrInvalidProtocol, got %v", grammar, err)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package strobe
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+//
+// Transcripts
+//
+// Transcripts are recorded in the JSON format of the test vectors (see
+// test_vectors/test_vectors.json).
+//
+
+// VectorOperation is holding a test vector operation
+type VectorOperation struct {
+	OpName string `json:"name"`
+
+	// for Init
+	OpCustomString string `json:"custom_string,omitempty"`
+	OpSecurity     int    `json:"security,omitempty"`
+	OpWidth        int    `json:"width,omitempty"`       // 0 for the default, see Config
+	OpRounds       int    `json:"rounds,omitempty"`      // 0 for the default, see Config
+	OpPermutation  string `json:"permutation,omitempty"` // name of a Config.Permutation
+	OpRole         string `json:"role,omitempty"`        // set by SetRole, see Role
+
+	// for other operations
+	OpMeta        bool   `json:"meta"`
+	OpInputData   string `json:"input_data,omitempty"`
+	OpInputLength int    `json:"input_length,omitempty"`
+	OpOutput      string `json:"output,omitempty"`
+	OpStateAfter  string `json:"state_after"`
+	OpStream      bool   `json:"stream"`
+}
+
+// TestVector is a named sequence of operations, starting with "init".
+type TestVector struct {
+	Name       string            `json:"name"`
+	Operations []VectorOperation `json:"operations"`
+}
+
+// TestVectors is the content of a test vector file.
+type TestVectors struct {
+	TestVectors []TestVector `json:"test_vectors"`
+}
+
+// config returns the Config of an "init" operation, without its Permutation.
+func (op *VectorOperation) config() Config {
+	return Config{Security: op.OpSecurity, Width: op.OpWidth, Rounds: op.OpRounds}
+}
+
+// role returns the role recorded by an "init" operation, or NoRole.
+func (op *VectorOperation) role() (Role, error) {
+	for _, r := range []Role{Initiator, Responder, Symmetric} {
+		if op.OpRole == r.String() {
+			return r, nil
+		}
+	}
+	if op.OpRole != "" {
+		return NoRole, fmt.Errorf("strobe: unknown role %q", op.OpRole)
+	}
+	return NoRole, nil
+}
+
+//
+// Recording
+//
+
+// Recorder is a Tracer that records every operation of a Strobe state as a
+// TestVector, which can be replayed with Replay.
+type Recorder struct {
+	vector TestVector
+}
+
+// NewRecorder returns a Recorder producing a TestVector called `name`.
+func NewRecorder(name string) *Recorder {
+	return &Recorder{vector: TestVector{Name: name}}
+}
+
+// InitStrobe initializes a Strobe state like TryInitStrobe, records the
+// initialization and attaches the Recorder to the state.
+func (r *Recorder) InitStrobe(customizationString string, security int) (Strobe, error) {
+	return r.InitStrobeWithConfig(customizationString, Config{Security: security})
+}
+
+// InitStrobeWithConfig is like InitStrobe but takes a Config, see
+// TryInitStrobeWithConfig.
+func (r *Recorder) InitStrobeWithConfig(customizationString string, config Config) (Strobe, error) {
+	s, err := TryInitStrobeWithConfig(customizationString, config)
+	if err != nil {
+		return s, err
+	}
+	init := VectorOperation{
+		OpName:         "init",
+		OpCustomString: customizationString,
+		OpSecurity:     config.Security,
+		OpWidth:        config.Width,
+		OpRounds:       config.Rounds,
+		OpStateAfter:   s.debugPrintState(),
+	}
+	if config.Permutation != nil {
+		init.OpPermutation = config.Permutation.Name()
+	}
+	r.vector.Operations = append(r.vector.Operations, init)
+	s.SetTracer(r, true)
+	return s, nil
+}
+
+// Trace records the operations, see Tracer. A role set by SetRole is
+// recorded in the "init" operation.
+func (r *Recorder) Trace(event *TraceEvent) {
+	if event.Kind == TraceSetRole && len(r.vector.Operations) > 0 {
+		r.vector.Operations[0].OpRole = event.Role.String()
+	}
+	if event.Kind != TraceOperate {
+		return
+	}
+	op := VectorOperation{
+		OpName:       event.Op.String(),
+		OpMeta:       event.Meta,
+		OpStateAfter: hex.EncodeToString(event.State),
+		OpStream:     event.More,
+	}
+	if flag(event.Op).needsLength() {
+		op.OpInputLength = event.InputLength
+	} else {
+		op.OpInputData = hex.EncodeToString(event.Input)
+	}
+	if len(event.Output) > 0 {
+		op.OpOutput = hex.EncodeToString(event.Output)
+	}
+	r.vector.Operations = append(r.vector.Operations, op)
+}
+
+// TestVector returns the operations recorded so far.
+func (r *Recorder) TestVector() TestVector {
+	vector := r.vector
+	vector.Operations = append([]VectorOperation(nil), r.vector.Operations...)
+	return vector
+}
+
+//
+// Replaying
+//
+
+// ErrReplayDiverged is wrapped by the *ReplayError returned by Replay.
+var ErrReplayDiverged = errors.New("strobe: replay diverged from the transcript")
+
+// ReplayError describes the first operation of a replayed transcript whose
+// output or state differs from the recorded one.
+type ReplayError struct {
+	Index     int             // index of the operation in the TestVector
+	Operation VectorOperation // the recorded operation
+	Field     string          // "output" or "state_after"
+	Actual    string          // the replayed value, hex encoded
+}
+
+func (e *ReplayError) Error() string {
+	expected := e.Operation.OpOutput
+	if e.Field == "state_after" {
+		expected = e.Operation.OpStateAfter
+	}
+	return fmt.Sprintf("strobe: replay diverged at operation %d (%s): %s is %s instead of %s",
+		e.Index, e.Operation.OpName, e.Field, e.Actual, expected)
+}
+
+func (e *ReplayError) Unwrap() error {
+	return ErrReplayDiverged
+}
+
+// Replay re-executes the operations of `vector` and checks their outputs
+// and states. It returns a *ReplayError for the first operation that
+// differs, or another error if the TestVector cannot be replayed.
+func Replay(vector TestVector) error {
+	if len(vector.Operations) == 0 || vector.Operations[0].OpName != "init" {
+		return errors.New("strobe: a transcript must start with init")
+	}
+	init := vector.Operations[0]
+	config := init.config()
+	if init.OpPermutation != "" {
+		if config.Permutation = permutationByName(init.OpPermutation); config.Permutation == nil {
+			return fmt.Errorf("strobe: cannot replay a transcript on the permutation %q", init.OpPermutation)
+		}
+	}
+	s, err := TryInitStrobeWithConfig(init.OpCustomString, config)
+	if err != nil {
+		return fmt.Errorf("strobe: cannot replay operation 0: %w", err)
+	}
+	role, err := init.role()
+	if err != nil {
+		return fmt.Errorf("strobe: cannot replay operation 0: %w", err)
+	}
+	if role != NoRole {
+		s.SetRole(role)
+	}
+	if state := s.debugPrintState(); state != init.OpStateAfter {
+		return &ReplayError{Index: 0, Operation: init, Field: "state_after", Actual: state}
+	}
+
+	for i, recorded := range vector.Operations[1:] {
+		i++
+		op, err := ParseOperation(recorded.OpName)
+		if err != nil {
+			return fmt.Errorf("strobe: cannot replay operation %d: %w", i, err)
+		}
+		input, err := hex.DecodeString(recorded.OpInputData)
+		if err != nil {
+			return fmt.Errorf("strobe: cannot replay operation %d: %w", i, err)
+		}
+		expected, err := hex.DecodeString(recorded.OpOutput)
+		if err != nil {
+			return fmt.Errorf("strobe: cannot replay operation %d: %w", i, err)
+		}
+		output, err := s.replayOperation(recorded.OpMeta, op, input, recorded.OpInputLength, recorded.OpStream)
+		if err != nil {
+			return fmt.Errorf("strobe: cannot replay operation %d: %w", i, err)
+		}
+		if !bytes.Equal(output, expected) {
+			return &ReplayError{Index: i, Operation: recorded, Field: "output", Actual: hex.EncodeToString(output)}
+		}
+		if state := s.debugPrintState(); state != recorded.OpStateAfter {
+			return &ReplayError{Index: i, Operation: recorded, Field: "state_after", Actual: state}
+		}
+	}
+	return nil
+}
+
+// replayOperation runs a recorded operation like TryOperateOp. The streaming
+// APIs also record operations that TryOperateOp refuses, which are run
+// through operate like these APIs do: the PRF of zero bytes that starts a
+// PRFReader, and the recv_MAC chunks written to a MACVerifier.
+func (s *Strobe) replayOperation(meta bool, op Operation, input []byte, length int, more bool) ([]byte, error) {
+	flags := metaFlags(op, meta)
+	switch {
+	case op == OpPRF && length == 0:
+		_, err := s.operate(flags, nil, nil, 0, more)
+		return nil, err
+	case op == OpRecvMAC && more:
+		failures, err := s.operate(flags, nil, input, 0, more)
+		if err != nil {
+			return nil, err
+		}
+		return []byte{failures}, nil
+	}
+	return s.TryOperateOp(meta, op, input, length, more)
+}
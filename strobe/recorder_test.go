@@ -0,0 +1,110 @@
+package strobe
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestReplayTestVectors(t *testing.T) {
+	content, err := os.ReadFile("test_vectors/test_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var testVectors TestVectors
+	if err := json.Unmarshal(content, &testVectors); err != nil {
+		t.Fatal(err)
+	}
+	for _, vector := range testVectors.TestVectors {
+		if err := Replay(vector); err != nil {
+			t.Fatalf("%s: %v", vector.Name, err)
+		}
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder("handshake")
+	s, err := recorder.InitStrobe("myProtocol", 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.KEY([]byte("key"))
+	s.Send_CLR(true, []byte("header"))
+	s.Send_AEAD(message, []byte("ad"))
+	s.Operate(false, "AD", message, 0, false)
+	s.Operate(false, "AD", message, 0, true)
+	s.Recv_MAC(false, make([]byte, 16))
+	s.RATCHET(32)
+	s.PRF(16)
+
+	vector := recorder.TestVector()
+	if vector.Name != "handshake" || len(vector.Operations) != 11 {
+		t.Fatal("unexpected transcript", vector)
+	}
+	if err := Replay(vector); err != nil {
+		t.Fatal(err)
+	}
+
+	// round-trip through JSON
+	content, _ := json.Marshal(vector)
+	var decoded TestVector
+	if err := json.Unmarshal(content, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if err := Replay(decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	// divergences
+	tampered := recorder.TestVector()
+	tampered.Operations[3].OpOutput = "00" + tampered.Operations[3].OpOutput[2:]
+	var replayErr *ReplayError
+	if err := Replay(tampered); !errors.As(err, &replayErr) || replayErr.Index != 3 || replayErr.Field != "output" {
+		t.Fatal("expected a divergence of the output of operation 3, got", err)
+	}
+	tampered = recorder.TestVector()
+	tampered.Operations[4].OpInputData = "00"
+	err = Replay(tampered)
+	if !errors.As(err, &replayErr) || replayErr.Index != 4 || replayErr.Field != "state_after" {
+		t.Fatal("expected a divergence of the state of operation 4, got", err)
+	}
+	if !errors.Is(err, ErrReplayDiverged) {
+		t.Fatal("ReplayError does not wrap ErrReplayDiverged")
+	}
+}
+
+func TestRecorderStreams(t *testing.T) {
+	// the first PRF operation of a PRFReader has no length
+	sender := NewRecorder("sender")
+	s, err := sender.InitStrobe("myProtocol", 128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.KEY([]byte("key"))
+	tag := s.Send_MAC(false, 32)
+	reader := s.PRFReader()
+	reader.Read(make([]byte, 10))
+	reader.Read(make([]byte, 300))
+	reader.Close()
+	if err := Replay(sender.TestVector()); err != nil {
+		t.Fatal(err)
+	}
+
+	// recv_MAC is streamed by a MACVerifier
+	receiver := NewRecorder("receiver")
+	r, err := receiver.InitStrobe("myProtocol", 128)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.KEY([]byte("key"))
+	v := r.NewMACVerifier(false)
+	v.Write(tag[:7])
+	v.Write(tag[7:])
+	if err := v.Verify(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Replay(receiver.TestVector()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,214 @@
+package strobe
+
+import (
+	"crypto/cipher"
+	"io"
+)
+
+//
+// Streaming API
+//
+// The functions in this file wrap the `more` streaming option of Operate.
+// While a stream is open, any other operation on the Strobe state fails
+// with ErrStreamInProgress (the high-level functions panic with it), and so
+// do Clone, Serialize and MarshalBinary. The state must not be copied either,
+// as the copy would stay locked.
+//
+
+// opWriter streams an operation without output through an io.WriteCloser.
+type opWriter struct {
+	s      *Strobe
+	flags  flag
+	w      io.Writer // transport, only for send_CLR
+	closed bool
+}
+
+// ADWriter returns an io.WriteCloser that streams everything written to it
+// into a single AD operation. No other operation can run until it is closed.
+func (s *Strobe) ADWriter(meta bool) io.WriteCloser {
+	return s.newOpWriter(metaFlags(OpAD, meta), nil)
+}
+
+// KeyWriter returns an io.WriteCloser that streams everything written to it
+// into a single KEY operation. No other operation can run until it is closed.
+func (s *Strobe) KeyWriter() io.WriteCloser {
+	return s.newOpWriter(flag(OpKEY), nil)
+}
+
+// SendCLRWriter returns an io.WriteCloser that streams everything written to
+// it into a single send_CLR operation, and forwards it to `w`. Only what `w`
+// accepts is absorbed. No other operation can run until it is closed.
+// Closing it does not close `w`.
+func (s *Strobe) SendCLRWriter(meta bool, w io.Writer) io.WriteCloser {
+	return s.newOpWriter(metaFlags(OpSendCLR, meta), w)
+}
+
+// newOpWriter starts the operation and locks the state.
+func (s *Strobe) newOpWriter(flags flag, w io.Writer) *opWriter {
+	s.mustOperate(flags, nil, nil, 0)
+	s.locked = true
+	return &opWriter{s: s, flags: flags, w: w}
+}
+
+// Write absorbs `p` in the streamed operation.
+func (ow *opWriter) Write(p []byte) (n int, err error) {
+	if ow.closed {
+		return 0, ErrStreamClosed
+	}
+	n = len(p)
+	if ow.w != nil {
+		n, err = ow.w.Write(p)
+	}
+	ow.s.locked = false
+	_, opErr := ow.s.operate(ow.flags, nil, p[:n], 0, true)
+	ow.s.locked = true
+	if opErr != nil {
+		return 0, opErr
+	}
+	return n, err
+}
+
+// Close ends the streamed operation and unlocks the state.
+func (ow *opWriter) Close() error {
+	if ow.closed {
+		return ErrStreamClosed
+	}
+	ow.closed = true
+	ow.s.locked = false
+	return nil
+}
+
+// prfReader streams the output of a PRF operation through an io.ReadCloser.
+type prfReader struct {
+	s      *Strobe
+	closed bool
+}
+
+// PRFReader returns an io.ReadCloser that reads an arbitrary amount of PRF
+// output, like an extendable-output function. Reading n bytes in total gives
+// the same output as PRF(n). No other operation can run until it is closed.
+func (s *Strobe) PRFReader() io.ReadCloser {
+	s.mustOperate(flag(OpPRF), nil, nil, 0)
+	s.locked = true
+	return &prfReader{s: s}
+}
+
+// Read fills `p` with the next bytes of PRF output. It never fails until the
+// reader is closed.
+func (pr *prfReader) Read(p []byte) (int, error) {
+	if pr.closed {
+		return 0, ErrStreamClosed
+	}
+	pr.s.locked = false
+	_, err := pr.s.operate(flag(OpPRF), p, nil, len(p), true)
+	pr.s.locked = true
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close ends the PRF operation and unlocks the state.
+func (pr *prfReader) Close() error {
+	if pr.closed {
+		return ErrStreamClosed
+	}
+	pr.closed = true
+	pr.s.locked = false
+	return nil
+}
+
+// encStream streams send_ENC or recv_ENC through a cipher.Stream.
+type encStream struct {
+	s     *Strobe
+	flags flag
+	ops   uint64 // s.ops when the operation started
+}
+
+// NewSendStream returns a cipher.Stream that encrypts with a single send_ENC
+// operation started on `s`. Other operations can be run on `s`, but the
+// stream can't be used afterwards.
+func NewSendStream(s *Strobe, meta bool) cipher.Stream {
+	return newEncStream(s, metaFlags(OpSendENC, meta))
+}
+
+// NewRecvStream returns a cipher.Stream that decrypts with a single recv_ENC
+// operation started on `s`. Other operations can be run on `s`, but the
+// stream can't be used afterwards.
+func NewRecvStream(s *Strobe, meta bool) cipher.Stream {
+	return newEncStream(s, metaFlags(OpRecvENC, meta))
+}
+
+func newEncStream(s *Strobe, flags flag) *encStream {
+	s.mustOperate(flags, nil, nil, 0)
+	return &encStream{s: s, flags: flags, ops: s.ops}
+}
+
+// XORKeyStream encrypts or decrypts `src` into `dst`. It panics if `dst` is
+// smaller than `src`, or if another operation was run on the Strobe state.
+func (es *encStream) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("strobe: output smaller than input")
+	}
+	if es.s.ops != es.ops {
+		panic(ErrStreamInterrupted)
+	}
+	if _, err := es.s.operate(es.flags, dst[:len(src)], src, 0, true); err != nil {
+		panic(err)
+	}
+}
+
+// MACVerifier verifies a tag received in chunks with a single recv_MAC
+// operation, see NewMACVerifier.
+type MACVerifier struct {
+	s        *Strobe
+	flags    flag
+	length   int  // length of the tag received so far
+	failures byte // 0 as long as the tag is correct
+	closed   bool
+}
+
+// NewMACVerifier starts a recv_MAC operation whose tag is written in chunks
+// to the returned MACVerifier. No other operation can run until Verify is
+// called.
+func (s *Strobe) NewMACVerifier(meta bool) *MACVerifier {
+	flags := metaFlags(OpRecvMAC, meta)
+	s.mustOperate(flags, nil, nil, 0)
+	s.locked = true
+	return &MACVerifier{s: s, flags: flags}
+}
+
+// Write absorbs the next chunk of the tag.
+func (v *MACVerifier) Write(p []byte) (int, error) {
+	if v.closed {
+		return 0, ErrStreamClosed
+	}
+	v.s.locked = false
+	failures, err := v.s.operate(v.flags, nil, p, 0, true)
+	v.s.locked = true
+	if err != nil {
+		return 0, err
+	}
+	v.failures |= failures
+	v.length += len(p)
+	return len(p), nil
+}
+
+// Verify ends the recv_MAC operation and unlocks the state. It returns
+// ErrMACTooShort if the tag is shorter than the minimum set with
+// SetMinMACLength, and ErrAuthenticationFailed if the tag is invalid.
+func (v *MACVerifier) Verify() error {
+	if v.closed {
+		return ErrStreamClosed
+	}
+	v.closed = true
+	v.s.locked = false
+	if err := v.s.checkMACLength(v.length); err != nil {
+		return err
+	}
+	if v.failures != 0 {
+		v.s.macFailed()
+		return ErrAuthenticationFailed
+	}
+	return nil
+}
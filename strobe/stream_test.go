@@ -0,0 +1,208 @@
+package strobe
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"io"
+	"testing"
+)
+
+func TestWriters(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+
+	s1 := InitStrobe("myHash", 128)
+	s2 := s1
+	s1.KEY(data)
+	s1.AD(true, data)
+	s1.Send_CLR(false, data)
+	s1.AD(false, []byte{})
+
+	kw := s2.KeyWriter()
+	if _, err := io.Copy(kw, bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	kw.Close()
+	aw := s2.ADWriter(true)
+	for _, chunk := range [][]byte{data[:1], data[1:1000], {}, data[1000:]} {
+		aw.Write(chunk)
+	}
+	aw.Close()
+	var transport bytes.Buffer
+	cw := s2.SendCLRWriter(false, &transport)
+	io.Copy(cw, bytes.NewReader(data))
+	cw.Close()
+	s2.ADWriter(false).Close()
+
+	if s1.debugPrintState() != s2.debugPrintState() {
+		t.Fatal("writers do not match the operations they stream")
+	}
+	if !bytes.Equal(transport.Bytes(), data) {
+		t.Fatal("SendCLRWriter did not forward the data")
+	}
+	if _, err := cw.Write(data); err != ErrStreamClosed {
+		t.Fatal("expected ErrStreamClosed, got", err)
+	}
+}
+
+func TestWriterLocks(t *testing.T) {
+	s := InitStrobe("myHash", 128)
+	w := s.ADWriter(false)
+	w.Write(message)
+
+	if _, err := s.TryOperate(false, "AD", message, 0, true); err != ErrStreamInProgress {
+		t.Fatal("expected ErrStreamInProgress, got", err)
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != ErrStreamInProgress {
+				t.Fatal("expected a panic with ErrStreamInProgress, got", r)
+			}
+		}()
+		s.PRF(16)
+	}()
+
+	// a locked state cannot be copied
+	if _, err := s.MarshalBinary(); err != ErrStreamInProgress {
+		t.Fatal("expected ErrStreamInProgress, got", err)
+	}
+	for _, copy := range []func(){func() { s.Serialize() }, func() { s.Clone() }} {
+		func() {
+			defer func() {
+				if r := recover(); r != ErrStreamInProgress {
+					t.Fatal("expected a panic with ErrStreamInProgress, got", r)
+				}
+			}()
+			copy()
+		}()
+	}
+
+	w.Close()
+	s.PRF(16)
+	if _, err := s.MarshalBinary(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPRFReader(t *testing.T) {
+	for _, size := range []int{1, 165, 166, 167, 1000, 10000} {
+		s1 := InitStrobe("myHash", 128)
+		s1.KEY([]byte("key"))
+		s2 := s1
+
+		expected := s1.PRF(size)
+		s1.AD(false, message)
+
+		r := s2.PRFReader()
+		out := make([]byte, size)
+		for read := 0; read < size; {
+			chunk := 1 + read%37
+			if read+chunk > size {
+				chunk = size - read
+			}
+			n, err := r.Read(out[read : read+chunk])
+			if err != nil || n != chunk {
+				t.Fatal("PRFReader failed to read", err)
+			}
+			read += n
+		}
+		if _, err := s2.TryOperate(false, "AD", message, 0, false); err != ErrStreamInProgress {
+			t.Fatal("expected ErrStreamInProgress, got", err)
+		}
+		r.Close()
+		s2.AD(false, message)
+
+		if !bytes.Equal(out, expected) {
+			t.Fatalf("%d: PRFReader does not match PRF", size)
+		}
+		if s1.debugPrintState() != s2.debugPrintState() {
+			t.Fatalf("%d: PRFReader does not leave the state as PRF", size)
+		}
+		if _, err := r.Read(out); err != ErrStreamClosed {
+			t.Fatal("expected ErrStreamClosed, got", err)
+		}
+	}
+}
+
+func TestCipherStreams(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 100)
+
+	sender := InitStrobe("myHash", 128)
+	sender.KEY([]byte("key"))
+	receiver := sender
+	expected := sender
+	ciphertext := expected.Send_ENC_unauthenticated(true, data)
+
+	// arbitrary chunking through cipher.StreamWriter
+	var transport bytes.Buffer
+	w := cipher.StreamWriter{S: NewSendStream(&sender, true), W: &transport}
+	for i := 0; i < len(data); i += 7 {
+		end := i + 7
+		if end > len(data) {
+			end = len(data)
+		}
+		w.Write(data[i:end])
+	}
+	if !bytes.Equal(transport.Bytes(), ciphertext) {
+		t.Fatal("send stream does not match Send_ENC_unauthenticated")
+	}
+
+	// and back through cipher.StreamReader
+	r := cipher.StreamReader{S: NewRecvStream(&receiver, true), R: &transport}
+	plaintext, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(plaintext, data) {
+		t.Fatal("recv stream cannot decrypt")
+	}
+	if sender.debugPrintState() != receiver.debugPrintState() || sender.debugPrintState() != expected.debugPrintState() {
+		t.Fatal("streams do not leave the state as Send_ENC_unauthenticated")
+	}
+
+	// interleaving is detected
+	stream := NewSendStream(&sender, false)
+	sender.AD(false, message)
+	defer func() {
+		if r := recover(); r != ErrStreamInterrupted {
+			t.Fatal("expected a panic with ErrStreamInterrupted, got", r)
+		}
+	}()
+	stream.XORKeyStream(data, data)
+}
+
+func TestMACVerifier(t *testing.T) {
+	sender := InitStrobe("myHash", 128)
+	sender.KEY([]byte("key"))
+	receiver := sender
+	tag := sender.Send_MAC(false, 32)
+
+	r1, r2, r3, r4 := receiver, receiver, receiver, receiver
+	v := r1.NewMACVerifier(false)
+	for _, chunk := range [][]byte{tag[:1], tag[1:20], {}, tag[20:]} {
+		v.Write(chunk)
+	}
+	if _, err := r1.TryOperate(false, "AD", message, 0, false); err != ErrStreamInProgress {
+		t.Fatal("expected ErrStreamInProgress, got", err)
+	}
+	if err := v.Verify(); err != nil {
+		t.Fatal("cannot verify a streamed tag:", err)
+	}
+	r2.Recv_MAC(false, tag)
+	if r1.debugPrintState() != r2.debugPrintState() || r1.debugPrintState() != sender.debugPrintState() {
+		t.Fatal("MACVerifier does not match Recv_MAC")
+	}
+
+	// invalid tag
+	v = r3.NewMACVerifier(false)
+	v.Write(tag[:10])
+	v.Write([]byte{tag[10] ^ 1})
+	v.Write(tag[11:])
+	if err := v.Verify(); err != ErrAuthenticationFailed {
+		t.Fatal("expected ErrAuthenticationFailed, got", err)
+	}
+
+	// truncated tag
+	r4.SetMinMACLength(16)
+	v = r4.NewMACVerifier(false)
+	v.Write(tag[:15])
+	if err := v.Verify(); err != ErrMACTooShort {
+		t.Fatal("expected ErrMACTooShort, got", err)
+	}
+}
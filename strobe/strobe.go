@@ -12,9 +12,10 @@ package strobe
 /***************************************************/
 
 import (
-	"bytes"
 	"encoding/binary"
 	"encoding/hex"
+	"errors"
+	"fmt"
 )
 
 const (
@@ -22,84 +23,202 @@ const (
 	MACLEN = 16
 )
 
+//
+// Errors
+//
+
+// Errors returned by the error-returning variants of the API (TryInitStrobe,
+// TryOperate and TryRecoverState). The panicking functions panic with the
+// same values.
+var (
+	// ErrUnknownOperation is returned when the operation is not a valid Strobe operation.
+	ErrUnknownOperation = errors.New("strobe: not a valid operation")
+	// ErrLengthRequired is returned when PRF, send_MAC or RATCHET are called without a length.
+	ErrLengthRequired = errors.New("strobe: a length should be set for this operation")
+	// ErrLengthNotAllowed is returned when a length is given to an operation that takes input data.
+	ErrLengthNotAllowed = errors.New("strobe: output length must be zero except for PRF, send_MAC and RATCHET operations")
+	// ErrNegativeLength is returned when the length given to an operation is negative.
+	ErrNegativeLength = errors.New("strobe: length must not be negative")
+	// ErrStreamingFlagMismatch is returned when `more` is used with a different operation than the previous one.
+	ErrStreamingFlagMismatch = errors.New("strobe: flags should be the same when streaming operations")
+	// ErrStreamingRecvMAC is returned when recv_MAC is called with the `more` streaming option.
+	ErrStreamingRecvMAC = errors.New("strobe: not supposed to check a MAC with the 'more' streaming option")
+	// ErrInvalidSecurity is returned when the security target is neither 128 nor 256,
+	// or is not supported by the width of the permutation (see Config).
+	ErrInvalidSecurity = errors.New("strobe: security must be set to either 128 or 256")
+	// ErrInvalidWidth is returned when a Config has an unsupported width.
+	ErrInvalidWidth = errors.New("strobe: width must be 1600, 800, 400 or 200")
+	// ErrInvalidRounds is returned when a Config has an unsupported number of rounds.
+	ErrInvalidRounds = errors.New("strobe: unsupported number of rounds")
+	// ErrInvalidState is returned when a serialized state cannot be recovered.
+	ErrInvalidState = errors.New("strobe: cannot recover invalid state")
+	// ErrStreamInProgress is returned when an operation is attempted while
+	// another one is streamed through an io.Writer or io.Reader.
+	ErrStreamInProgress = errors.New("strobe: an operation is being streamed, it must be closed first")
+	// ErrStreamClosed is returned when writing to or reading from a closed stream.
+	ErrStreamClosed = errors.New("strobe: stream is closed")
+	// ErrStreamInterrupted is the panic value of a cipher.Stream whose
+	// operation was interrupted by another operation.
+	ErrStreamInterrupted = errors.New("strobe: another operation was started during the stream")
+	// ErrAuthenticationFailed is returned when an authentication tag is invalid.
+	ErrAuthenticationFailed = errors.New("strobe: message authentication failed")
+	// ErrMACTooShort is returned when a received tag is missing or shorter
+	// than the minimum set with SetMinMACLength.
+	ErrMACTooShort = errors.New("strobe: authentication tag is missing or truncated")
+	// ErrStatePoisoned is returned for any operation on a state whose MAC
+	// verification failed in abort on failure mode, see SetAbortOnFailure.
+	ErrStatePoisoned = errors.New("strobe: state is poisoned by a failed MAC verification")
+	// ErrInvalidRole is returned by SetRole for an invalid role.
+	ErrInvalidRole = errors.New("strobe: role must be Initiator, Responder or Symmetric")
+	// ErrRoleAlreadySet is returned by SetRole when the role was already set
+	// by SetRole or by a transport operation.
+	ErrRoleAlreadySet = errors.New("strobe: role is already set")
+	// ErrInvalidKeySize is returned by NewAEAD when the key is shorter than the security target.
+	ErrInvalidKeySize = errors.New("strobe: key is shorter than the security target")
+	// ErrInvalidTagSize is returned by NewAEADWithTagSize when the tag size is too small.
+	ErrInvalidTagSize = errors.New("strobe: invalid tag size")
+)
+
 // KEY inserts a key into the state.
 // It also provides forward secrecy.
 func (s *Strobe) KEY(key []byte) {
-	s.Operate(false, "KEY", key, 0, false)
+	s.mustOperate(flag(OpKEY), nil, key, 0)
 }
 
 // PRF provides a hash of length `output_len` of all previous operations
 // It can also be used to generate random numbers, it is forward secure.
 func (s *Strobe) PRF(outputLen int) []byte {
-	return s.Operate(false, "PRF", []byte{}, outputLen, false)
+	return s.OperateOp(false, OpPRF, []byte{}, outputLen, false)
 }
 
 // Send_ENC_unauthenticated is used to encrypt some plaintext
 // it should be followed by Send_MAC in order to protect its integrity
 // `meta` is used for encrypted framing data.
 func (s *Strobe) Send_ENC_unauthenticated(meta bool, plaintext []byte) []byte {
-	return s.Operate(meta, "send_ENC", plaintext, 0, false)
+	return s.OperateOp(meta, OpSendENC, plaintext, 0, false)
 }
 
 // Recv_ENC_unauthenticated is used to decrypt some received ciphertext
 // it should be followed by Recv_MAC in order to protect its integrity
 // `meta` is used for decrypting framing data.
 func (s *Strobe) Recv_ENC_unauthenticated(meta bool, ciphertext []byte) []byte {
-	return s.Operate(meta, "recv_ENC", ciphertext, 0, false)
+	return s.OperateOp(meta, OpRecvENC, ciphertext, 0, false)
 }
 
 // AD allows you to authenticate Additional Data
 // it should be followed by a Send_MAC or Recv_MAC in order to truly work
 func (s *Strobe) AD(meta bool, additionalData []byte) {
-	s.Operate(meta, "AD", additionalData, 0, false)
+	s.mustOperate(metaFlags(OpAD, meta), nil, additionalData, 0)
 }
 
 // Send_CLR allows you to send data in cleartext
 // `meta` is used to send framing data
 func (s *Strobe) Send_CLR(meta bool, cleartext []byte) {
-	s.Operate(meta, "send_CLR", cleartext, 0, false)
+	s.mustOperate(metaFlags(OpSendCLR, meta), nil, cleartext, 0)
 }
 
 // Recv_CLR allows you to receive data in cleartext.
 // `meta` is used to receive framing data
 func (s *Strobe) Recv_CLR(meta bool, cleartext []byte) {
-	s.Operate(meta, "recv_CLR", cleartext, 0, false)
+	s.mustOperate(metaFlags(OpRecvCLR, meta), nil, cleartext, 0)
 }
 
 // Send_MAC allows you to produce an authentication tag.
 // `meta` is appropriate for checking the integrity of framing data.
 func (s *Strobe) Send_MAC(meta bool, output_length int) []byte {
-	return s.Operate(meta, "send_MAC", []byte{}, output_length, false)
+	return s.OperateOp(meta, OpSendMAC, []byte{}, output_length, false)
 }
 
 // Recv_MAC allows you to verify a received authentication tag.
 // `meta` is appropriate for checking the integrity of framing data.
+// Tags shorter than the minimum set with SetMinMACLength are rejected.
 func (s *Strobe) Recv_MAC(meta bool, MAC []byte) bool {
-	if s.Operate(meta, "recv_MAC", MAC, 0, false)[0] == 0 {
-		return true
+	return s.RecvMAC(meta, MAC) == nil
+}
+
+// RecvMAC is like Recv_MAC but returns ErrMACTooShort if the tag is shorter
+// than the minimum set with SetMinMACLength (the operation is not run), and
+// ErrAuthenticationFailed if the tag is invalid.
+func (s *Strobe) RecvMAC(meta bool, MAC []byte) error {
+	if err := s.checkMACLength(len(MAC)); err != nil {
+		return err
+	}
+	if s.mustOperate(metaFlags(OpRecvMAC, meta), nil, MAC, 0) != 0 {
+		return ErrAuthenticationFailed
+	}
+	return nil
+}
+
+// SetMinMACLength sets the minimum length of the tags accepted by Recv_MAC,
+// RecvMAC, MACVerifier, Recv_AEAD and RecvAEAD. Tags can never be empty, so
+// values lower than 1 set the minimum to 1 (the default).
+func (s *Strobe) SetMinMACLength(length int) {
+	if length < 1 {
+		length = 1
+	}
+	s.minMACLength = length
+}
+
+// MinMACLength returns the minimum length of the tags accepted, see
+// SetMinMACLength.
+func (s *Strobe) MinMACLength() int {
+	if s.minMACLength < 1 {
+		return 1
+	}
+	return s.minMACLength
+}
+
+// checkMACLength returns ErrMACTooShort if a tag of `length` bytes is not
+// accepted.
+func (s *Strobe) checkMACLength(length int) error {
+	if length < s.MinMACLength() {
+		s.macFailed()
+		return ErrMACTooShort
+	}
+	return nil
+}
+
+// SetAbortOnFailure enables or disables the abort on failure mode. In this
+// mode, a failed MAC verification poisons the state: every operation after
+// that fails with ErrStatePoisoned (the high-level functions panic with it),
+// and Clone and Serialize panic. The mode is recorded by Serialize.
+func (s *Strobe) SetAbortOnFailure(enabled bool) {
+	s.abortOnFailure = enabled
+}
+
+// Poisoned returns true if a MAC verification failed in abort on failure
+// mode, see SetAbortOnFailure.
+func (s *Strobe) Poisoned() bool {
+	return s.poisoned
+}
+
+// macFailed is called when a MAC verification fails.
+func (s *Strobe) macFailed() {
+	if s.abortOnFailure {
+		s.poisoned = true
 	}
-	return false
 }
 
 // RATCHET allows you to introduce forward secrecy in a protocol.
 func (s *Strobe) RATCHET(length int) {
-	s.Operate(false, "RATCHET", []byte{}, length, false)
+	s.OperateOp(false, OpRATCHET, []byte{}, length, false)
 }
 
 // Send_AEAD allows you to encrypt data and authenticate additional data
 // It is similar to AES-GCM.
 func (s *Strobe) Send_AEAD(plaintext, ad []byte) (ciphertext []byte) {
-	ciphertext = append(ciphertext, s.Send_ENC_unauthenticated(false, plaintext)...)
-	s.AD(false, ad)
-	ciphertext = append(ciphertext, s.Send_MAC(false, MACLEN)...)
-	return
+	return s.SendAEAD(nil, plaintext, ad)
 }
 
 // Recv_AEAD allows you to decrypt data and authenticate additional data
 // It is similar to AES-GCM.
 func (s *Strobe) Recv_AEAD(ciphertext, ad []byte) (plaintext []byte, ok bool) {
 	if len(ciphertext) < MACLEN {
-		ok = false
+		// a truncated input is a failed verification
+		s.macFailed()
+		return
+	}
+	if s.checkMACLength(MACLEN) != nil {
 		return
 	}
 	plaintext = s.Recv_ENC_unauthenticated(false, ciphertext[:len(ciphertext)-MACLEN])
@@ -108,74 +227,265 @@ func (s *Strobe) Recv_AEAD(ciphertext, ad []byte) (plaintext []byte, ok bool) {
 	return
 }
 
+//
+// Allocation-free API
+//
+// These functions write their output into caller-provided buffers, in the
+// style of crypto/cipher.AEAD, and do not allocate as long as the buffers
+// are large enough. To reuse the storage of the input for the output, use
+// in[:0] as dst. Otherwise the input and dst must not overlap.
+//
+
+// PRFInto fills `dst` with a hash of all previous operations, see PRF.
+func (s *Strobe) PRFInto(dst []byte) {
+	if len(dst) == 0 {
+		panic(ErrLengthRequired)
+	}
+	s.mustOperate(flag(OpPRF), dst, nil, len(dst))
+}
+
+// SendENC appends the encryption of `plaintext` to `dst` and returns the
+// resulting slice, see Send_ENC_unauthenticated.
+func (s *Strobe) SendENC(meta bool, dst, plaintext []byte) []byte {
+	ret, out := sliceForAppend(dst, len(plaintext))
+	s.mustOperate(metaFlags(OpSendENC, meta), out, plaintext, 0)
+	return ret
+}
+
+// RecvENC appends the decryption of `ciphertext` to `dst` and returns the
+// resulting slice, see Recv_ENC_unauthenticated.
+func (s *Strobe) RecvENC(meta bool, dst, ciphertext []byte) []byte {
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	s.mustOperate(metaFlags(OpRecvENC, meta), out, ciphertext, 0)
+	return ret
+}
+
+// SendENCInPlace encrypts `buf` in place, see Send_ENC_unauthenticated.
+func (s *Strobe) SendENCInPlace(meta bool, buf []byte) {
+	s.mustOperate(metaFlags(OpSendENC, meta), buf, buf, 0)
+}
+
+// RecvENCInPlace decrypts `buf` in place, see Recv_ENC_unauthenticated.
+func (s *Strobe) RecvENCInPlace(meta bool, buf []byte) {
+	s.mustOperate(metaFlags(OpRecvENC, meta), buf, buf, 0)
+}
+
+// SendMACInto fills `tag` with an authentication tag, see Send_MAC.
+func (s *Strobe) SendMACInto(meta bool, tag []byte) {
+	if len(tag) == 0 {
+		panic(ErrLengthRequired)
+	}
+	s.mustOperate(metaFlags(OpSendMAC, meta), tag, nil, len(tag))
+}
+
+// SendAEAD appends the encryption of `plaintext` followed by a MACLEN-byte
+// authentication tag to `dst` and returns the resulting slice, see Send_AEAD.
+func (s *Strobe) SendAEAD(dst, plaintext, ad []byte) []byte {
+	ret, out := sliceForAppend(dst, len(plaintext)+MACLEN)
+	s.mustOperate(flag(OpSendENC), out, plaintext, 0)
+	s.mustOperate(flag(OpAD), nil, ad, 0)
+	s.mustOperate(flag(OpSendMAC), out[len(plaintext):], nil, MACLEN)
+	return ret
+}
+
+// RecvAEAD appends the decryption of `ciphertext` to `dst` and returns the
+// resulting slice, see Recv_AEAD. If the authentication tag is invalid, the
+// decrypted output is zeroed and ErrAuthenticationFailed is returned. If the
+// ciphertext is too short to contain a tag, or if MACLEN is lower than the
+// minimum set with SetMinMACLength, ErrMACTooShort is returned.
+func (s *Strobe) RecvAEAD(dst, ciphertext, ad []byte) ([]byte, error) {
+	if len(ciphertext) < MACLEN {
+		// a truncated input is a failed verification
+		s.macFailed()
+		return nil, ErrMACTooShort
+	}
+	if err := s.checkMACLength(MACLEN); err != nil {
+		return nil, err
+	}
+	tag := ciphertext[len(ciphertext)-MACLEN:]
+	ciphertext = ciphertext[:len(ciphertext)-MACLEN]
+	ret, out := sliceForAppend(dst, len(ciphertext))
+	s.mustOperate(flag(OpRecvENC), out, ciphertext, 0)
+	s.mustOperate(flag(OpAD), nil, ad, 0)
+	if s.mustOperate(flag(OpRecvMAC), nil, tag, 0) != 0 {
+		for i := range out {
+			out[i] = 0
+		}
+		return nil, ErrAuthenticationFailed
+	}
+	return ret, nil
+}
+
 //
 // Strobe Objects
 //
 
-type role uint8 // for strobe.I0
+// Role is the role of a party in a protocol (Strobe's I0). By default, it is
+// set by the first transport operation, see SetRole.
+type Role uint8
 
 const (
-	iInitiator role = iota // set if we send the first transport message
-	iResponder             // set if we receive the first transport message
-	iNone                  // starting value
+	Initiator Role = iota // set if we send the first transport message
+	Responder             // set if we receive the first transport message
+	NoRole                // starting value
+	// Symmetric is for peer-to-peer protocols in which both parties can send
+	// first: the direction of transport operations is not absorbed in the
+	// state, so that send_X on one side and recv_X on the other side absorb
+	// the same flags whoever sent first.
+	Symmetric
 )
 
-/*
-  We do not use strobe's `pos` variable here since it is easily
-  obtainable via `len(buf)`
-*/
-// TODO: accept permutations of different sizes
+// String returns the name of the role.
+func (r Role) String() string {
+	switch r {
+	case Initiator:
+		return "Initiator"
+	case Responder:
+		return "Responder"
+	case NoRole:
+		return "NoRole"
+	case Symmetric:
+		return "Symmetric"
+	}
+	return fmt.Sprintf("Role(%d)", uint8(r))
+}
+
+// SetRole sets the role of the party (Initiator, Responder or Symmetric)
+// instead of letting the first transport operation decide it. It returns
+// ErrRoleAlreadySet if a role was already set, by SetRole or by a transport
+// operation.
+func (s *Strobe) SetRole(r Role) error {
+	if r != Initiator && r != Responder && r != Symmetric {
+		return ErrInvalidRole
+	}
+	if s.I0 != NoRole {
+		return ErrRoleAlreadySet
+	}
+	s.I0 = r
+	if s.tracer != nil {
+		s.tracer.Trace(&TraceEvent{Kind: TraceSetRole, Role: r, State: s.stateBytes()})
+	}
+	return nil
+}
+
+// Role returns the role of the party, or NoRole if it isn't set yet.
+func (s *Strobe) Role() Role {
+	return s.I0
+}
+
+// Strobe is a Strobe state. It only contains fixed-size arrays, so that a
+// plain copy of the struct (s2 := s1) is an independent clone of the state.
+// A state must not be copied while an operation is streamed through an
+// io.Writer or io.Reader (see ADWriter): nothing could unlock the copy.
 type Strobe struct {
 	// config
-	duplexRate int // 1600/8 - security/4
+	perm       Permutation
+	duplexRate int // perm.Size() - security/4
 	StrobeR    int // duplexRate - 2
 
 	// strobe specific
 	initialized bool  // used to avoid padding during the first permutation
 	posBegin    uint8 // start of the current operation (0 := previous block)
-	I0          role
+
+	// I0 is the role of the party.
+	//
+	// Deprecated: use Role and SetRole.
+	I0 Role
+
+	// minimum length of received MACs (0 means 1)
+	minMACLength int
+
+	// tracing (see SetTracer)
+	tracer    Tracer
+	traceData bool
+
+	// abort on failure mode (see SetAbortOnFailure)
+	abortOnFailure bool
+	poisoned       bool // set after a failed MAC in abort on failure mode
+
+	// protocol enforcement (see SetProtocol)
+	protocol      *Protocol
+	protocolState int
 
 	// streaming API
 	curFlags flag
+	locked   bool   // set while an io.Writer or io.Reader streams an operation
+	ops      uint64 // number of operations started, to detect interleaving
 
-	// duplex construction (see sha3.go)
-	a            [25]uint64 // the actual state
-	buf          []byte     // a pointer into the storage, it also serves as `pos` variable
-	storage      []byte     // to-be-XORed (used for optimizations purposes)
-	tempStateBuf []byte     // utility slice used for temporary duplexing operations
+	// duplex construction
+	state [maxStateSize]byte // the actual state (its first perm.Size() bytes)
+	pos   int                // position in the rate
 }
 
 // Clone allows you to clone a Strobe state.
+// Since a Strobe state can be copied, this is the same as copying it.
+// It panics with ErrStatePoisoned if the state is poisoned, and with
+// ErrStreamInProgress if an operation is being streamed.
 func (s Strobe) Clone() *Strobe {
-	ret := s
-	// need to recreate some buffers
-	ret.storage = make([]byte, s.duplexRate)
-	copy(ret.storage, s.storage)
-	ret.tempStateBuf = make([]byte, s.duplexRate)
-	copy(ret.tempStateBuf, s.tempStateBuf)
-	// and set pointers
-	ret.buf = ret.storage[:len(ret.buf)]
-	return &ret
+	if s.poisoned {
+		panic(ErrStatePoisoned)
+	}
+	if s.locked {
+		panic(ErrStreamInProgress)
+	}
+	return &s
 }
 
+// serializedLength is the length of a serialized Keccak-f[1600] state, the
+// length of other widths is 10 + width/8.
+const serializedLength = 6 + 1600/8 + 4
+
 // Serialize allows one to serialize the strobe state to later recover it.
-// [security(1)|initialized(1)|I0(1)|curFlags(1)|posBegin(1)|pos(1)|[25]uint64 state]
+// [security(1)|initialized(1)|I0(1)|curFlags(1)|posBegin(1)|pos(1)|state(width/8)|minMACLength(4)]
+// The permutation is given by the length of the state: Keccak-f[width], or
+// Xoodoo[12] for 48 bytes. The `security` byte is 0 for 128-bit security, 1
+// for 256 and 2 for 64, plus 4 times the number of rounds removed from the
+// default of Keccak-f (see Config). The `initialized` byte also records the
+// abort on failure mode in its second bit, and minMACLength is the minimum
+// tag length (see SetMinMACLength) in big-endian.
+// It panics with ErrStatePoisoned if the state is poisoned, with
+// ErrStreamInProgress if an operation is being streamed, and with
+// ErrNotSerializable if it runs on a permutation other than Keccak-p or
+// Xoodoo[12]. MarshalBinary returns errors instead, and its format is
+// versioned and records every setting of the state.
 func (s Strobe) Serialize() []byte {
+	if s.poisoned {
+		panic(ErrStatePoisoned)
+	}
+	if s.locked {
+		panic(ErrStreamInProgress)
+	}
+	var removedRounds int
+	switch p := s.perm.(type) {
+	case keccakPermutation:
+		removedRounds = keccakRounds(uint(p.width/25)) - p.rounds
+	case xoodooPermutation:
+	default:
+		panic(ErrNotSerializable)
+	}
 	// serialized data
-	serialized := make([]byte, 6+25*8) // TODO: this is only for keccak-f[1600]
+	size := s.perm.Size()
+	serialized := make([]byte, 6+size+4)
 	// security?
-	security := (1600/8 - s.duplexRate) * 4
-	if security == 128 {
+	switch security := (size - s.duplexRate) * 4; security {
+	case 128:
 		serialized[0] = 0
-	} else {
+	case 256:
 		serialized[0] = 1
+	default:
+		serialized[0] = 2
 	}
-	// initialized?
+	// rounds?
+	serialized[0] |= byte(removedRounds) << 2
+	// initialized? + abort on failure?
 	if s.initialized {
 		serialized[1] = 1
 	} else {
 		serialized[1] = 0
 	}
+	if s.abortOnFailure {
+		serialized[1] |= 2
+	}
 	// I0
 	serialized[2] = byte(s.I0)
 	// curFlags
@@ -183,70 +493,104 @@ func (s Strobe) Serialize() []byte {
 	// posBegin
 	serialized[4] = byte(s.posBegin)
 	// pos
-	serialized[5] = byte(len(s.buf))
-	// make sure to XOR what's left to XOR in the storage
-	var buf [1600 / 8]byte
-	var state [25]uint64
-	copy(buf[:len(s.buf)], s.storage[:len(s.buf)]) // len(s.buf) = pos
-	copy(state[:], s.a[:])
-	xorState(&state, buf[:])
+	serialized[5] = byte(s.pos)
 	// state
-	var b []byte
-	b = serialized[6:]
-	for i := 0; len(b) >= 8; i++ {
-		binary.LittleEndian.PutUint64(b, state[i])
-		b = b[8:]
-	}
+	copy(serialized[6:], s.stateBytes())
+	// minimum MAC length
+	binary.BigEndian.PutUint32(serialized[6+size:], uint32(s.MinMACLength()))
 	//
 	return serialized
 }
 
 // Recover state allows one to re-create a strobe state from a serialized state.
-// [security(1)|initialized(1)|I0(1)|curFlags(1)|posBegin(1)|pos(1)|[25]uint64 state]
-func RecoverState(serialized []byte) (s Strobe) {
-	if len(serialized) != 6+25*8 {
-		panic("strobe: cannot recover state of invalid length")
+// [security(1)|initialized(1)|I0(1)|curFlags(1)|posBegin(1)|pos(1)|state(width/8)|minMACLength(4)]
+// The states serialized without minMACLength by older versions are
+// recovered with the default minimum of 1.
+// It panics if the serialized state is invalid, see TryRecoverState.
+func RecoverState(serialized []byte) Strobe {
+	s, err := TryRecoverState(serialized)
+	if err != nil {
+		panic(err)
 	}
-	// security?
-	if serialized[0] > 1 {
-		panic("strobe: cannot recover state with invalid security")
+	return s
+}
+
+// TryRecoverState is like RecoverState but returns an error wrapping
+// ErrInvalidState instead of panicking on an invalid serialized state.
+func TryRecoverState(serialized []byte) (s Strobe, err error) {
+	if len(serialized) < 6 {
+		return s, fmt.Errorf("%w: invalid length %d", ErrInvalidState, len(serialized))
 	}
-	security := 128
-	if security == 1 {
-		security = 256
+	// minimum MAC length?
+	size, minMACLength := len(serialized)-6, uint32(1)
+	switch size {
+	case 200 + 4, 100 + 4, 50 + 4, 25 + 4, xoodooSize + 4:
+		size -= 4
+		minMACLength = binary.BigEndian.Uint32(serialized[6+size:])
 	}
-	// init vars from security
-	s.duplexRate = 1600/8 - security/4
-	s.StrobeR = s.duplexRate - 2
-	// need to recreate some buffers
-	s.storage = make([]byte, s.duplexRate)
-	s.tempStateBuf = make([]byte, s.duplexRate)
-	// initialized?
-	if serialized[1] == 1 {
-		s.initialized = true
-	} else {
-		s.initialized = false
+	// permutation? + security? + rounds?
+	var config Config
+	switch size {
+	case 200, 100, 50, 25:
+		config.Width = size * 8
+		config.Rounds = keccakRounds(uint(size*8/25)) - int(serialized[0]>>2)
+	case xoodooSize:
+		if serialized[0]>>2 != 0 {
+			return s, fmt.Errorf("%w: invalid rounds", ErrInvalidState)
+		}
+		config.Permutation = xoodooPermutation{}
+	default:
+		return s, fmt.Errorf("%w: invalid length %d", ErrInvalidState, len(serialized))
 	}
-	// I0?
-	if serialized[2] > 3 {
-		panic("strobe: cannot recover state with invalid role")
+	switch serialized[0] & 3 {
+	case 0:
+		config.Security = 128
+	case 1:
+		config.Security = 256
+	case 2:
+		config.Security = 64
+	default:
+		return s, fmt.Errorf("%w: invalid security", ErrInvalidState)
 	}
-	s.I0 = role(serialized[2])
-	// curFlags + posBegin
-	s.curFlags = flag(serialized[3])
-	s.posBegin = uint8(serialized[4])
-	// pos
-	pos := int(serialized[5])
-	s.buf = s.storage[:pos]
-	// state
-	serialized = serialized[6:]
-	for i := 0; i < 25; i++ {
-		a := binary.LittleEndian.Uint64(serialized[:8])
-		s.a[i] = a
-		serialized = serialized[8:]
+	if err := config.check(); err != nil {
+		return s, fmt.Errorf("%w: %v", ErrInvalidState, err)
 	}
-	//
-	return
+	// init vars from the config
+	s.configure(config)
+	// initialized? + abort on failure? + I0? + curFlags? + posBegin? + pos?
+	err = s.restore(serialized[1], serialized[2], serialized[3], serialized[4], serialized[5], minMACLength, serialized[6:6+size])
+	return s, err
+}
+
+// restore checks and sets the fields decoded by TryRecoverState and
+// UnmarshalBinary, on a state configured with the decoded Config. `flags`
+// is 1 if the state is initialized, | 2 in abort on failure mode.
+func (s *Strobe) restore(flags, role, curFlags, posBegin, pos byte, minMACLength uint32, state []byte) error {
+	if flags > 3 {
+		return fmt.Errorf("%w: invalid flags", ErrInvalidState)
+	}
+	s.initialized = flags&1 != 0
+	s.abortOnFailure = flags&2 != 0
+	if role > byte(Symmetric) {
+		return fmt.Errorf("%w: invalid role", ErrInvalidState)
+	}
+	s.I0 = Role(role)
+	s.curFlags = flag(curFlags)
+	if !Operation(s.curFlags &^ flagM).valid() {
+		return fmt.Errorf("%w: invalid current operation", ErrInvalidState)
+	}
+	if minMACLength < 1 || minMACLength > 1<<31-1 {
+		return fmt.Errorf("%w: invalid minimum MAC length", ErrInvalidState)
+	}
+	s.SetMinMACLength(int(minMACLength))
+	// the current operation absorbed its header at posBegin-1 and posBegin,
+	// unless it started on a previous block (posBegin = 0)
+	s.posBegin, s.pos = posBegin, int(pos)
+	if s.pos >= s.StrobeR || s.posBegin != 0 && int(s.posBegin) >= s.pos {
+		return fmt.Errorf("%w: invalid position", ErrInvalidState)
+	}
+	copy(s.state[:], state)
+	return nil
 }
 
 //
@@ -261,261 +605,658 @@ const (
 	flagC
 	flagT
 	flagM
+	// flagK is reserved by the Strobe specification for a key tree mode that
+	// it does not define, so no operation sets it. Following the key tree
+	// transcripts of another implementation needs the exact specification of
+	// its mode and transcripts to check against.
 	flagK
 )
 
-var operationMap = map[string]flag{
-	"AD":       flagA,
-	"KEY":      flagA | flagC,
-	"PRF":      flagI | flagA | flagC,
-	"send_CLR": flagA | flagT,
-	"recv_CLR": flagI | flagA | flagT,
-	"send_ENC": flagA | flagC | flagT,
-	"recv_ENC": flagI | flagA | flagC | flagT,
-	"send_MAC": flagC | flagT,
-	"recv_MAC": flagI | flagC | flagT,
-	"RATCHET":  flagC,
+//
+// Operations
+//
+
+// Operation is a Strobe operation, its value is the set of flags that
+// defines the operation in the Strobe specification (without the M flag).
+type Operation uint8
+
+// The operations defined by the Strobe specification.
+const (
+	OpAD      = Operation(flagA)
+	OpKEY     = Operation(flagA | flagC)
+	OpPRF     = Operation(flagI | flagA | flagC)
+	OpSendCLR = Operation(flagA | flagT)
+	OpRecvCLR = Operation(flagI | flagA | flagT)
+	OpSendENC = Operation(flagA | flagC | flagT)
+	OpRecvENC = Operation(flagI | flagA | flagC | flagT)
+	OpSendMAC = Operation(flagC | flagT)
+	OpRecvMAC = Operation(flagI | flagC | flagT)
+	OpRATCHET = Operation(flagC)
+)
+
+// String returns the name of the operation as used in the Strobe
+// specification (and in the string-based Operate).
+func (op Operation) String() string {
+	switch op {
+	case OpAD:
+		return "AD"
+	case OpKEY:
+		return "KEY"
+	case OpPRF:
+		return "PRF"
+	case OpSendCLR:
+		return "send_CLR"
+	case OpRecvCLR:
+		return "recv_CLR"
+	case OpSendENC:
+		return "send_ENC"
+	case OpRecvENC:
+		return "recv_ENC"
+	case OpSendMAC:
+		return "send_MAC"
+	case OpRecvMAC:
+		return "recv_MAC"
+	case OpRATCHET:
+		return "RATCHET"
+	}
+	return fmt.Sprintf("Operation(%#02x)", uint8(op))
+}
+
+// ParseOperation returns the operation named `name` (for example "send_ENC"),
+// or ErrUnknownOperation.
+func ParseOperation(name string) (Operation, error) {
+	switch name {
+	case "AD":
+		return OpAD, nil
+	case "KEY":
+		return OpKEY, nil
+	case "PRF":
+		return OpPRF, nil
+	case "send_CLR":
+		return OpSendCLR, nil
+	case "recv_CLR":
+		return OpRecvCLR, nil
+	case "send_ENC":
+		return OpSendENC, nil
+	case "recv_ENC":
+		return OpRecvENC, nil
+	case "send_MAC":
+		return OpSendMAC, nil
+	case "recv_MAC":
+		return OpRecvMAC, nil
+	case "RATCHET":
+		return OpRATCHET, nil
+	}
+	return 0, ErrUnknownOperation
+}
+
+// valid returns true if op is one of the operations of the specification.
+func (op Operation) valid() bool {
+	switch op {
+	case OpAD, OpKEY, OpPRF, OpSendCLR, OpRecvCLR, OpSendENC, OpRecvENC, OpSendMAC, OpRecvMAC, OpRATCHET:
+		return true
+	}
+	return false
 }
 
 //
 // Helper
 //
 
-// this only works for 8-byte alligned buffers
-func xorState(state *[25]uint64, buf []byte) {
-	n := len(buf) / 8
-	for i := 0; i < n; i++ {
-		a := binary.LittleEndian.Uint64(buf)
-		state[i] ^= a
-		buf = buf[8:]
+// metaFlags returns the flags of `op`, with the M flag set if `meta` is true.
+func metaFlags(op Operation, meta bool) flag {
+	if meta {
+		return flag(op) | flagM
 	}
+	return flag(op)
+}
+
+// cAfter returns true if the output of the operation is computed after
+// absorbing its input (send_ENC and send_MAC)
+func (f flag) cAfter() bool {
+	return f&(flagC|flagI|flagT) == flagC|flagT
+}
+
+// cBefore returns true if the input of the operation is XORed with the
+// state before being absorbed
+func (f flag) cBefore() bool {
+	return f&flagC != 0 && !f.cAfter()
+}
+
+// needsLength returns true for operations that take an output length
+// instead of input data (PRF, send_MAC and RATCHET)
+func (f flag) needsLength() bool {
+	return (f&(flagI|flagT) != (flagI | flagT)) && (f&(flagI|flagA) != flagA)
+}
+
+// isRecvMAC returns true for recv_MAC
+func (f flag) isRecvMAC() bool {
+	return f&(flagI|flagA|flagT) == (flagI | flagT)
+}
+
+// hasOutput returns true for operations returning data to the application
+// or to the transport
+func (f flag) hasOutput() bool {
+	return f&(flagI|flagA) == (flagI|flagA) || f&(flagI|flagT) == flagT
 }
 
-// this only works for 8-byte alligned buffers
-func outState(state [25]uint64, b []byte) {
-	for i := 0; len(b) >= 8; i++ {
-		binary.LittleEndian.PutUint64(b, state[i])
-		b = b[8:]
+// sliceForAppend takes a slice and a requested number of bytes. It returns a
+// slice with the contents of the given slice followed by that many bytes and a
+// second slice that aliases into it and contains only the extra bytes.
+// (from crypto/cipher)
+func sliceForAppend(in []byte, n int) (head, tail []byte) {
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
 	}
+	tail = head[len(in):]
+	return
 }
 
-// since the golang implementation does not absorb
-// things in the state "right away" (sometimes just
-// wait for the buffer to fill) we need a function
-// to properly print the state even when the state
-// is in this "temporary" state.
+// zeros is the input of operations that only take a length
+var zeros [1600 / 8]byte
+
+// debugPrintState returns the state, hex encoded.
 func (s Strobe) debugPrintState() string {
-	// copy _storage into buf
-	var buf [1600 / 8]byte
-	copy(buf[:len(s.buf)], s.storage[:len(s.buf)])
-	// copy _state into state
-	var state [25]uint64
-	copy(state[:], s.a[:])
-	// xor
-	xorState(&state, buf[:])
-	// print
-	outState(state, buf[:])
-	return hex.EncodeToString(buf[:])
+	return hex.EncodeToString(s.stateBytes())
+}
+
+// stateBytes returns a copy of the state.
+func (s *Strobe) stateBytes() []byte {
+	return append([]byte(nil), s.state[:s.perm.Size()]...)
 }
 
 //
 // Core functions
 //
 
+// Config holds the parameters of a Strobe instance, see InitStrobeWithConfig.
+// The zero value of each field selects the default of the Strobe
+// specification, but Security must be set.
+type Config struct {
+	// Security is the security target, either 128 or 256. The rate of the
+	// duplex is Width/8 - Security/4 bytes, so 256-bit security needs a
+	// width of at least 800 bits, and 128-bit security at least 400 bits.
+	// Permutations too small for 128-bit security, like Keccak-f[200], only
+	// support a security target of 64.
+	Security int
+	// Width is the width in bits of the Keccak-f permutation: 1600 (the
+	// default), or 800, 400 or 200 for Strobe-lite on small devices.
+	Width int
+	// Rounds is the number of rounds of the Keccak-p permutation. It
+	// defaults to the rounds of Keccak-f: 24 for a width of 1600, 22 for
+	// 800, 20 for 400 and 18 for 200. With a width of 1600, it must be a
+	// multiple of 4, like the 12 rounds of KangarooTwelve and TurboSHAKE.
+	// Fewer rounds are faster but have a lower security margin. States using
+	// a reduced number of rounds absorb a different domain string at
+	// initialization, and can never produce the same outputs as standard
+	// states.
+	Rounds int
+	// Permutation replaces Keccak-p[Width, Rounds] by another permutation,
+	// in which case Width and Rounds must be zero. Unless it is named
+	// Keccak-f[b] (see Permutation), its name is absorbed in the domain
+	// string at initialization.
+	Permutation Permutation
+}
+
+// withDefaults returns the Config with its zero fields set to their default.
+func (c Config) withDefaults() Config {
+	if c.Permutation != nil {
+		return c
+	}
+	if c.Width == 0 {
+		c.Width = 1600
+	}
+	if c.Rounds == 0 {
+		c.Rounds = keccakRounds(uint(c.Width / 25))
+	}
+	return c
+}
+
+// check returns an error if a Config with its defaults set is invalid.
+func (c Config) check() error {
+	var size int
+	if c.Permutation != nil {
+		size = c.Permutation.Size()
+		if c.Width != 0 || c.Rounds != 0 || size <= 0 || size > maxStateSize {
+			return ErrInvalidPermutation
+		}
+	} else {
+		if _, err := NewKeccakP(c.Width, c.Rounds); err != nil {
+			return err
+		}
+		size = c.Width / 8
+	}
+	switch {
+	case c.Security == 64 && size-128/4 <= 2 && size-64/4 > 2:
+	case (c.Security == 128 || c.Security == 256) && size-c.Security/4 > 2:
+	default:
+		return ErrInvalidSecurity
+	}
+	return nil
+}
+
+// permutation returns the Permutation of a valid Config.
+func (c Config) permutation() Permutation {
+	if c.Permutation != nil {
+		return c.Permutation
+	}
+	return keccakPermutation{width: c.Width, rounds: c.Rounds}
+}
+
 // InitStrobe allows you to initialize a new strobe instance with a customization string (that can be empty) and a security target (either 128 or 256).
-func InitStrobe(customizationString string, security int) (s Strobe) {
+// It panics if the security target is invalid, see TryInitStrobe.
+func InitStrobe(customizationString string, security int) Strobe {
+	s, err := TryInitStrobe(customizationString, security)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// TryInitStrobe is like InitStrobe but returns ErrInvalidSecurity instead of
+// panicking if the security target is neither 128 nor 256.
+func TryInitStrobe(customizationString string, security int) (s Strobe, err error) {
+	return TryInitStrobeWithConfig(customizationString, Config{Security: security})
+}
+
+// InitStrobeWithConfig is like InitStrobe but takes the parameters of the
+// instance in a Config. It panics if the Config is invalid, see
+// TryInitStrobeWithConfig.
+func InitStrobeWithConfig(customizationString string, config Config) Strobe {
+	s, err := TryInitStrobeWithConfig(customizationString, config)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// TryInitStrobeWithConfig is like InitStrobeWithConfig but returns
+// ErrInvalidSecurity, ErrInvalidWidth, ErrInvalidRounds or
+// ErrInvalidPermutation instead of panicking.
+func TryInitStrobeWithConfig(customizationString string, config Config) (s Strobe, err error) {
 	// compute security and rate
-	if security != 128 && security != 256 {
-		panic("strobe: security must be set to either 128 or 256")
+	config = config.withDefaults()
+	if err = config.check(); err != nil {
+		return s, err
 	}
-	s.duplexRate = 1600/8 - security/4
-	s.StrobeR = s.duplexRate - 2
+	s.configure(config)
 	// init vars
-	s.storage = make([]byte, s.duplexRate)
-	s.tempStateBuf = make([]byte, s.duplexRate)
-	s.I0 = iNone
+	s.I0 = NoRole
 	s.initialized = false
 	// absorb domain + initialize + absorb custom string
-	domain := []byte{1, byte(s.StrobeR + 2), 1, 0, 1, 12 * 8}
-	domain = append(domain, []byte("STROBEv1.0.2")...)
-	s.buf = s.storage[:0]
-	s.duplex(domain, false, false, true)
+	domain := []byte{1, byte(s.StrobeR + 2), 1, 0}
+	domain = appendEncodeString(domain, s.domainString())
+	s.duplex(nil, domain, false, false, true)
 	s.initialized = true
-	s.Operate(true, "AD", []byte(customizationString), 0, false)
+	if _, err = s.TryOperateOp(true, OpAD, []byte(customizationString), 0, false); err != nil {
+		return s, err
+	}
 
-	return
+	return s, nil
 }
 
-// runF: applies the STROBE's + cSHAKE's padding and the Keccak permutation
-func (s *Strobe) runF() {
-	if s.initialized {
-		// if we're initialize we apply the strobe padding
-		if len(s.buf) > s.StrobeR {
-			panic("strobe: buffer is never supposed to reach strobeR")
-		}
-		s.buf = append(s.buf, s.posBegin)
-		s.buf = append(s.buf, 0x04)
-		zerosStart := len(s.buf)
-		s.buf = s.storage[:s.duplexRate]
-		for i := zerosStart; i < s.duplexRate; i++ {
-			s.buf[i] = 0
-		}
-		s.buf[s.duplexRate-1] ^= 0x80
-		xorState(&s.a, s.buf)
-	} else if len(s.buf) != 0 {
-		// otherwise we just pad with 0s for xorState to work
-		zerosStart := len(s.buf) // rate = [0--end_of_buffer/zeroStart---duplexRate]
-		s.buf = s.storage[:s.duplexRate]
-		for i := zerosStart; i < s.duplexRate; i++ {
-			s.buf[i] = 0
-		}
-		xorState(&s.a, s.buf)
+// configure sets the parameters of the state from a valid Config.
+func (s *Strobe) configure(config Config) {
+	s.perm = config.permutation()
+	s.duplexRate = s.perm.Size() - config.Security/4
+	s.StrobeR = s.duplexRate - 2
+}
+
+// domainString returns the cSHAKE customization string of the instance,
+// which is "STROBEv1.0.2" for Keccak-f of any width.
+func (s *Strobe) domainString() string {
+	if !isKeccakF(s.perm) {
+		return "STROBEv1.0.2/" + s.perm.Name()
+	}
+	return "STROBEv1.0.2"
+}
+
+// appendEncodeString appends cSHAKE's encode_string(str) to b, that is
+// left_encode(len(str) in bits) followed by str.
+func appendEncodeString(b []byte, str string) []byte {
+	var length [8]byte
+	binary.BigEndian.PutUint64(length[:], uint64(len(str))*8)
+	n := 7
+	for n > 0 && length[7-n] == 0 {
+		n--
 	}
+	b = append(b, byte(n+1))
+	b = append(b, length[7-n:]...)
+	return append(b, str...)
+}
+
+// runF: applies the STROBE's + cSHAKE's padding and the permutation
+func (s *Strobe) runF() {
+	s.padF()
 
 	// run the permutation
-	keccakF1600(&s.a, 24)
+	s.perm.Permute(s.state[:s.perm.Size()])
 
-	// reset the buffer and set posBegin to 0
+	// reset the position and set posBegin to 0
 	// (meaning that the current operation started on a previous block)
-	s.buf = s.storage[:0]
+	s.pos = 0
 	s.posBegin = 0
 }
 
-// duplex: the duplex call
-func (s *Strobe) duplex(data []byte, cbefore, cafter, forceF bool) {
-
-	// process data block by block
-	for len(data) > 0 {
-
-		todo := s.StrobeR - len(s.buf)
-		if todo > len(data) {
-			todo = len(data)
-		}
-
-		if cbefore {
-			outState(s.a, s.tempStateBuf)
-			for idx, state := range s.tempStateBuf[len(s.buf) : len(s.buf)+todo] {
-				data[idx] ^= state
-			}
+// padF: the part of runF before the permutation, XORs the padding into the
+// state
+func (s *Strobe) padF() {
+	if s.initialized {
+		// if we're initialize we apply the strobe padding
+		if s.pos > s.StrobeR {
+			panic("strobe: buffer is never supposed to reach strobeR")
 		}
+		s.state[s.pos] ^= s.posBegin
+		s.state[s.pos+1] ^= 0x04
+		s.state[s.duplexRate-1] ^= 0x80
+	}
+}
 
-		// buffer what's to be XOR'ed (we XOR once during runF)
-		s.buf = append(s.buf, data[:todo]...)
-
-		if cafter {
-			outState(s.a, s.tempStateBuf)
-			for idx, state := range s.tempStateBuf[len(s.buf)-todo : len(s.buf)] {
-				data[idx] ^= state
-			}
-		}
+// duplex: the duplex call
+// `src` is absorbed and, if `dst` is not nil, the output is written in `dst`
+// (which can be `src` for in-place operations).
+func (s *Strobe) duplex(dst, src []byte, cbefore, cafter, forceF bool) {
+	// process data block by block
+	for len(src) > 0 {
+		todo := s.duplexBlock(dst, src, cbefore, cafter)
 
 		// what's next for the loop?
-		data = data[todo:]
+		src = src[todo:]
+		if dst != nil {
+			dst = dst[todo:]
+		}
 
-		// If the duplex is full, time to XOR + padd + permutate.
-		if len(s.buf) == s.StrobeR {
+		// If the duplex is full, time to padd + permutate.
+		if s.pos == s.StrobeR {
 			s.runF()
 		}
 
 	}
 
 	// sometimes we the next operation to start on a new block
-	if forceF && len(s.buf) != 0 {
+	if forceF && s.pos != 0 {
 		s.runF()
 	}
 
 	return
 }
 
-// Operate runs an operation (see OperationMap for a list of operations).
+// duplexBlock: the duplex call up to the end of the current block, without
+// the permutation. It returns the number of bytes of `src` processed.
+func (s *Strobe) duplexBlock(dst, src []byte, cbefore, cafter bool) int {
+	todo := s.StrobeR - s.pos
+	if todo > len(src) {
+		todo = len(src)
+	}
+
+	state := s.state[s.pos : s.pos+todo]
+	src = src[:todo]
+
+	if cbefore {
+		// the output is the input XOR the state, which then becomes the input
+		if dst != nil {
+			xorAndReplace(dst[:todo], src, state)
+		} else {
+			copy(state, src)
+		}
+	} else {
+		xorInto(state, src)
+		if cafter && dst != nil {
+			copy(dst, state)
+		} else if dst != nil {
+			copy(dst, src)
+		}
+	}
+	s.pos += todo
+	return todo
+}
+
+// xorInto: XORs `src` into `dst`, a word at a time
+func xorInto(dst, src []byte) {
+	i := 0
+	for ; i+8 <= len(src); i += 8 {
+		w := binary.LittleEndian.Uint64(dst[i:]) ^ binary.LittleEndian.Uint64(src[i:])
+		binary.LittleEndian.PutUint64(dst[i:], w)
+	}
+	for ; i < len(src); i++ {
+		dst[i] ^= src[i]
+	}
+}
+
+// xorAndReplace: writes `src` XOR `state` in `dst` (which can be `src`) and
+// replaces `state` with `src`, a word at a time
+func xorAndReplace(dst, src, state []byte) {
+	i := 0
+	for ; i+8 <= len(src); i += 8 {
+		w := binary.LittleEndian.Uint64(src[i:])
+		binary.LittleEndian.PutUint64(dst[i:], w^binary.LittleEndian.Uint64(state[i:]))
+		binary.LittleEndian.PutUint64(state[i:], w)
+	}
+	for ; i < len(src); i++ {
+		b := src[i]
+		dst[i] = b ^ state[i]
+		state[i] = b
+	}
+}
+
+// Operate runs an operation given by its name (see ParseOperation).
 // For operations that only require a length, provide the length via the
 // length argument with an empty slice []byte{}. For other operations provide
 // a zero length.
 // Result is always retrieved through the return value. For boolean results,
 // check that the first index is 0 for true, 1 for false.
+// It panics on invalid arguments, see TryOperate.
 func (s *Strobe) Operate(meta bool, operation string, dataConst []byte, length int, more bool) []byte {
-	// operation is valid?
-	var flags flag
-	var ok bool
-	if flags, ok = operationMap[operation]; !ok {
-		panic("not a valid operation")
+	out, err := s.TryOperate(meta, operation, dataConst, length, more)
+	if err != nil {
+		panic(err)
 	}
+	return out
+}
 
-	// operation is meta?
-	if meta {
-		flags |= flagM
+// TryOperate is like Operate but returns an error instead of panicking on
+// invalid arguments. The state is left untouched when an error is returned.
+func (s *Strobe) TryOperate(meta bool, operation string, dataConst []byte, length int, more bool) ([]byte, error) {
+	op, err := ParseOperation(operation)
+	if err != nil {
+		return nil, err
 	}
+	return s.TryOperateOp(meta, op, dataConst, length, more)
+}
 
-	// does the operation requires a length?
-	var data []byte
+// OperateOp is like Operate but takes a typed Operation instead of its name.
+func (s *Strobe) OperateOp(meta bool, op Operation, dataConst []byte, length int, more bool) []byte {
+	out, err := s.TryOperateOp(meta, op, dataConst, length, more)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
 
-	if (flags&(flagI|flagT) != (flagI | flagT)) && (flags&(flagI|flagA) != flagA) {
+// TryOperateOp is like TryOperate but takes a typed Operation instead of its name.
+func (s *Strobe) TryOperateOp(meta bool, op Operation, dataConst []byte, length int, more bool) ([]byte, error) {
+	// operation is valid?
+	if !op.valid() {
+		return nil, ErrUnknownOperation
+	}
+	flags := metaFlags(op, meta)
 
+	// does the operation requires a length?
+	if length < 0 {
+		return nil, ErrNegativeLength
+	}
+	if flags.needsLength() {
 		if length == 0 {
-			panic("A length should be set for this operation.")
+			return nil, ErrLengthRequired
 		}
+	} else if length != 0 {
+		return nil, ErrLengthNotAllowed
+	}
 
-		data = bytes.Repeat([]byte{0}, length)
+	// Check MAC: it is not possible to stream it
+	if more && flags.isRecvMAC() {
+		return nil, ErrStreamingRecvMAC
+	}
 
-	} else {
-		if length != 0 {
-			panic("Output length must be zero except for PRF, send_MAC and RATCHET operations.")
+	var out []byte
+	if flags.hasOutput() {
+		if flags.needsLength() {
+			out = make([]byte, length)
+		} else {
+			out = make([]byte, len(dataConst))
 		}
+	}
+	if flags.needsLength() {
+		dataConst = nil
+	}
+
+	failures, err := s.operate(flags, out, dataConst, length, more)
+	if err != nil {
+		return nil, err
+	}
+	if flags.isRecvMAC() {
+		return []byte{failures}, nil // 0 if correct, else the OR of the output bytes
+	}
+	return out, nil
+}
+
+// mustOperate is operate for the high-level functions, which cannot produce
+// invalid arguments. It does not allocate.
+func (s *Strobe) mustOperate(flags flag, dst, src []byte, length int) byte {
+	failures, err := s.operate(flags, dst, src, length, false)
+	if err != nil {
+		panic(err)
+	}
+	return failures
+}
 
-		data = make([]byte, len(dataConst))
-		copy(data, dataConst)
+// operate is the core of every operation. It runs the operation defined by
+// `flags` on `src` and writes its output (if any) in `dst`, which must be
+// as long as `src`. Operations that require a length process `length` zero
+// bytes instead of `src`. For recv_MAC, the returned value is 0 if the MAC
+// is correct.
+func (s *Strobe) operate(flags flag, dst, src []byte, length int, more bool) (failures byte, err error) {
+	// has a MAC verification failed?
+	if s.poisoned {
+		return 0, ErrStatePoisoned
+	}
+
+	// is an operation being streamed?
+	if s.locked {
+		return 0, ErrStreamInProgress
 	}
 
 	// is this call the continuity of a previous call?
 	if more {
 		if flags != s.curFlags {
-			panic("Flag should be the same when streaming operations.")
+			return 0, ErrStreamingFlagMismatch
 		}
 	} else {
+		if s.protocol != nil {
+			next, err := s.checkProtocol(flags)
+			if err != nil {
+				return 0, err
+			}
+			s.protocolState = next
+		}
 		s.beginOp(flags)
 		s.curFlags = flags
+		if s.tracer != nil {
+			s.traceBeginOp(flags)
+		}
+	}
+
+	// keep what the tracer needs before the data is modified
+	var input, output []byte
+	processed := length
+	if s.tracer != nil {
+		if s.traceData {
+			input = append([]byte{}, src...)
+		}
+		output = dst
+		if !flags.needsLength() {
+			processed = len(src)
+			if dst != nil {
+				output = dst[:len(src)]
+			}
+		}
 	}
 
 	// Operation
-	cAfter := (flags & (flagC | flagI | flagT)) == (flagC | flagT)
-	cBefore := (flags&flagC != 0) && (!cAfter)
-
-	s.duplex(data, cBefore, cAfter, false)
-
-	if (flags & (flagI | flagA)) == (flagI | flagA) {
-		// Return data for the application
-		return data
-	} else if (flags & (flagI | flagT)) == flagT {
-		// Return data for the transport.
-		return data
-	} else if (flags & (flagI | flagA | flagT)) == (flagI | flagT) {
+	cAfter, cBefore := flags.cAfter(), flags.cBefore()
+
+	switch {
+	case flags.needsLength():
+		for length > 0 {
+			todo := length
+			if todo > len(zeros) {
+				todo = len(zeros)
+			}
+			if dst != nil {
+				s.duplex(dst[:todo], zeros[:todo], cBefore, cAfter, false)
+				dst = dst[todo:]
+			} else {
+				s.duplex(nil, zeros[:todo], cBefore, cAfter, false)
+			}
+			length -= todo
+		}
+	case flags.isRecvMAC():
 		// Check MAC: all output bytes must be 0
-		if more {
-			panic("not supposed to check a MAC with the 'more' streaming option")
+		var buf [32]byte
+		for len(src) > 0 {
+			todo := len(src)
+			if todo > len(buf) {
+				todo = len(buf)
+			}
+			s.duplex(buf[:todo], src[:todo], cBefore, cAfter, false)
+			for _, b := range buf[:todo] {
+				failures |= b
+			}
+			src = src[todo:]
 		}
-		var failures byte
-		for _, dataByte := range data {
-			failures |= dataByte
+		// streamed MACs are checked by MACVerifier.Verify
+		if failures != 0 && !more {
+			s.macFailed()
 		}
-		return []byte{failures} // 0 if correct, 1 if not
+	default:
+		s.duplex(dst, src, cBefore, cAfter, false)
 	}
 
-	// Operation has no output
-	return nil
+	if s.tracer != nil {
+		s.traceOperate(flags, input, output, processed, failures, more)
+	}
+
+	return failures, nil
 }
 
 // beginOp: starts an operation
 func (s *Strobe) beginOp(flags flag) {
+	header := s.opHeader(flags)
+	forceF := (flags&(flagC|flagK) != 0)
+	s.duplex(nil, header[:], false, false, forceF)
+}
+
+// opHeader: sets the role and posBegin for a new operation and returns
+// what the operation absorbs first
+func (s *Strobe) opHeader(flags flag) [2]byte {
 
 	if flags&flagT != 0 {
-		if s.I0 == iNone {
-			s.I0 = role(flags & flagI)
+		if s.I0 == NoRole {
+			s.I0 = Role(flags & flagI)
+		}
+		if s.I0 == Symmetric {
+			flags &^= flagI
+		} else {
+			flags ^= flag(s.I0)
 		}
-		flags ^= flag(s.I0)
 	}
 
+	s.ops++
+
 	oldBegin := s.posBegin
-	s.posBegin = uint8(len(s.buf) + 1) // s.pos + 1
-	forceF := (flags&(flagC|flagK) != 0)
-	s.duplex([]byte{oldBegin, byte(flags)}, false, false, forceF)
+	s.posBegin = uint8(s.pos + 1)
+	return [2]byte{oldBegin, byte(flags)}
 }
@@ -1,7 +1,10 @@
 package strobe
 
 import (
+	"bytes"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"testing"
 )
 
@@ -101,4 +104,532 @@ func TestRecover(t *testing.T) {
 	if out1 != out2 {
 		t.Fatal("strobe cannot serialize/recover correctly")
 	}
+
+	// the security level is recovered
+	for _, security := range []int{128, 256} {
+		s1 := InitStrobe("serialize", security)
+		s2 := RecoverState(s1.Serialize())
+		if s2.duplexRate != s1.duplexRate {
+			t.Fatalf("RecoverState does not recover the security of a %d-bit state", security)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	if _, err := TryInitStrobe("myHash", 64); err != ErrInvalidSecurity {
+		t.Fatal("expected ErrInvalidSecurity, got", err)
+	}
+
+	s := InitStrobe("myHash", 128)
+	before := s.debugPrintState()
+
+	tests := []struct {
+		operation string
+		data      []byte
+		length    int
+		more      bool
+		err       error
+	}{
+		{"encrypt", message, 0, false, ErrUnknownOperation},
+		{"PRF", []byte{}, 0, false, ErrLengthRequired},
+		{"send_MAC", []byte{}, 0, false, ErrLengthRequired},
+		{"PRF", []byte{}, -1, false, ErrNegativeLength},
+		{"RATCHET", []byte{}, -32, false, ErrNegativeLength},
+		{"AD", message, 16, false, ErrLengthNotAllowed},
+		{"AD", message, -1, false, ErrNegativeLength},
+		{"send_ENC", message, 0, true, ErrStreamingFlagMismatch},
+		{"recv_MAC", message, 0, true, ErrStreamingRecvMAC},
+	}
+	for _, test := range tests {
+		if _, err := s.TryOperate(false, test.operation, test.data, test.length, test.more); err != test.err {
+			t.Fatalf("%s: expected %v, got %v", test.operation, test.err, err)
+		}
+	}
+	if s.debugPrintState() != before {
+		t.Fatal("a failed operation modified the state")
+	}
+
+	// panicking variants panic with the same errors
+	for _, negative := range []func(){
+		func() { s.PRF(-1) },
+		func() { s.Send_MAC(false, -1) },
+		func() { s.RATCHET(-1) },
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r != ErrNegativeLength {
+					t.Fatal("expected a panic with ErrNegativeLength, got", r)
+				}
+			}()
+			negative()
+		}()
+	}
+	defer func() {
+		if r := recover(); r != ErrUnknownOperation {
+			t.Fatal("expected a panic with ErrUnknownOperation, got", r)
+		}
+	}()
+	s.Operate(false, "encrypt", message, 0, false)
+}
+
+func TestRecoverErrors(t *testing.T) {
+	s := InitStrobe("myHash", 128)
+	s.AD(false, message)
+	serialized := s.Serialize()
+
+	invalid := [][]byte{
+		serialized[:len(serialized)-1],
+		append([]byte{12}, serialized[1:]...),
+		append(append([]byte{}, serialized[:2]...), append([]byte{4}, serialized[3:]...)...),
+		append(append([]byte{}, serialized[:5]...), append([]byte{255}, serialized[6:]...)...),
+		append(append([]byte{}, serialized[:len(serialized)-4]...), 0, 0, 0, 0),
+		append(append([]byte{}, serialized[:len(serialized)-4]...), 0x80, 0, 0, 0),
+		append(append([]byte{}, serialized[:3]...), append([]byte{0xff}, serialized[4:]...)...),
+		append(append([]byte{}, serialized[:4]...), append([]byte{serialized[5]}, serialized[5:]...)...),
+	}
+	for i, serialized := range invalid {
+		if _, err := TryRecoverState(serialized); !errors.Is(err, ErrInvalidState) {
+			t.Fatalf("%d: expected ErrInvalidState, got %v", i, err)
+		}
+	}
+
+	if _, err := TryRecoverState(serialized); err != nil {
+		t.Fatal("cannot recover a valid state:", err)
+	}
+}
+
+func TestOperation(t *testing.T) {
+	ops := []Operation{OpAD, OpKEY, OpPRF, OpSendCLR, OpRecvCLR, OpSendENC, OpRecvENC, OpSendMAC, OpRecvMAC, OpRATCHET}
+	for _, op := range ops {
+		parsed, err := ParseOperation(op.String())
+		if err != nil || parsed != op {
+			t.Fatalf("%s: cannot parse its own name", op)
+		}
+	}
+	if _, err := ParseOperation("encrypt"); err != ErrUnknownOperation {
+		t.Fatal("expected ErrUnknownOperation, got", err)
+	}
+
+	s1 := InitStrobe("myHash", 128)
+	s2 := s1.Clone()
+	s1.Operate(false, "send_ENC", message, 0, false)
+	out1 := hex.EncodeToString(s1.Operate(false, "PRF", []byte{}, 32, false))
+	s2.OperateOp(false, OpSendENC, message, 0, false)
+	out2 := hex.EncodeToString(s2.OperateOp(false, OpPRF, []byte{}, 32, false))
+	if out1 != out2 {
+		t.Fatal("typed operations do not match named operations")
+	}
+
+	if _, err := s1.TryOperateOp(false, Operation(flagK), message, 0, false); err != ErrUnknownOperation {
+		t.Fatal("expected ErrUnknownOperation, got", err)
+	}
+}
+
+func TestAppendAPI(t *testing.T) {
+	s1 := InitStrobe("myHash", 128)
+	s2 := s1.Clone()
+	s1.KEY([]byte("key"))
+	s2.KEY([]byte("key"))
+
+	ciphertext := s1.Send_ENC_unauthenticated(false, message)
+	out := s2.SendENC(false, []byte("prefix"), message)
+	if string(out[:6]) != "prefix" || !bytes.Equal(out[6:], ciphertext) {
+		t.Fatal("SendENC does not match Send_ENC_unauthenticated")
+	}
+
+	buf := append([]byte{}, ciphertext...)
+	s1.RecvENCInPlace(true, buf)
+	plaintext := s2.Recv_ENC_unauthenticated(true, ciphertext)
+	if !bytes.Equal(buf, plaintext) {
+		t.Fatal("RecvENCInPlace does not match Recv_ENC_unauthenticated")
+	}
+
+	prf := make([]byte, 300)
+	s1.PRFInto(prf)
+	if !bytes.Equal(prf, s2.PRF(300)) {
+		t.Fatal("PRFInto does not match PRF")
+	}
+
+	// AEAD
+	a1 := InitStrobe("myHash", 128)
+	a1.KEY([]byte("key"))
+	a2, r1, r2 := a1.Clone(), a1.Clone(), a1.Clone()
+
+	sealed := a1.SendAEAD(nil, message, []byte("ad"))
+	if !bytes.Equal(sealed, a2.Send_AEAD(message, []byte("ad"))) {
+		t.Fatal("SendAEAD does not match Send_AEAD")
+	}
+	opened, err := r1.RecvAEAD(nil, sealed, []byte("ad"))
+	if err != nil || !bytes.Equal(opened, message) {
+		t.Fatal("RecvAEAD cannot decrypt SendAEAD")
+	}
+	sealed[0] ^= 1
+	if _, err := r2.RecvAEAD(nil, sealed, []byte("ad")); err != ErrAuthenticationFailed {
+		t.Fatal("expected ErrAuthenticationFailed, got", err)
+	}
+}
+
+func TestAppendAPIAllocations(t *testing.T) {
+	s := InitStrobe("myHash", 128)
+	key := []byte("key")
+	buf := make([]byte, 64)
+	tag := make([]byte, MACLEN)
+	sealed := make([]byte, 0, len(buf)+MACLEN)
+	allocs := testing.AllocsPerRun(100, func() {
+		s.KEY(key)
+		s.AD(false, message)
+		s.SendENCInPlace(false, buf)
+		s.RecvENCInPlace(false, buf)
+		s.SendMACInto(false, tag)
+		s.Recv_MAC(false, tag)
+		s.PRFInto(buf)
+		s.RATCHET(32)
+		sealed = s.SendAEAD(sealed[:0], buf, message)
+	})
+	if allocs != 0 {
+		t.Fatal("expected no allocations, got", allocs)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	s1 := InitStrobe("myHash", 128)
+	s1.KEY([]byte("key"))
+	s1.Operate(false, "AD", message, 0, false) // stops in the middle of a block
+	s2 := s1
+	before := s2.debugPrintState()
+
+	// modifying the original does not modify the copy
+	s1.Operate(false, "AD", message, 0, true)
+	s1.Send_ENC_unauthenticated(false, message)
+	if s2.debugPrintState() != before {
+		t.Fatal("modifying a state modifies its copy")
+	}
+
+	// and the copy behaves like the original
+	s3 := s2
+	s2.Operate(false, "AD", message, 0, true)
+	s2.Send_ENC_unauthenticated(false, message)
+	if s1.debugPrintState() != s2.debugPrintState() {
+		t.Fatal("a copy does not behave like the original state")
+	}
+	if s3.debugPrintState() != before {
+		t.Fatal("modifying a copy modifies another copy")
+	}
+
+	// copies are independent even when produced by RecoverState
+	s4 := RecoverState(s3.Serialize())
+	s5 := s4
+	s4.PRF(200)
+	if s5.debugPrintState() != before {
+		t.Fatal("modifying a recovered state modifies its copy")
+	}
+}
+
+func TestMACLength(t *testing.T) {
+	s := InitStrobe("myHash", 128)
+	s.KEY([]byte("key"))
+
+	// the empty tag is always rejected
+	if s.Recv_MAC(false, []byte{}) {
+		t.Fatal("Recv_MAC accepted an empty tag")
+	}
+	if err := s.RecvMAC(false, nil); err != ErrMACTooShort {
+		t.Fatal("expected ErrMACTooShort, got", err)
+	}
+
+	sender, receiver := s, s
+	tag := sender.Send_MAC(false, 8)
+	receiver.SetMinMACLength(16)
+	if err := receiver.RecvMAC(false, tag); err != ErrMACTooShort {
+		t.Fatal("expected ErrMACTooShort, got", err)
+	}
+	receiver.SetMinMACLength(8)
+	if err := receiver.RecvMAC(false, tag); err != nil {
+		t.Fatal("cannot verify tag:", err)
+	}
+
+	// Recv_AEAD follows the same policy
+	sender, receiver = s, s
+	sealed := sender.Send_AEAD(message, nil)
+	receiver.SetMinMACLength(MACLEN + 1)
+	if _, ok := receiver.Recv_AEAD(sealed, nil); ok {
+		t.Fatal("Recv_AEAD accepted a tag shorter than the minimum")
+	}
+	if _, err := receiver.RecvAEAD(nil, sealed, nil); err != ErrMACTooShort {
+		t.Fatal("expected ErrMACTooShort, got", err)
+	}
+	if _, err := receiver.RecvAEAD(nil, sealed[:MACLEN-1], nil); err != ErrMACTooShort {
+		t.Fatal("expected ErrMACTooShort, got", err)
+	}
+	receiver.SetMinMACLength(MACLEN)
+	if _, ok := receiver.Recv_AEAD(sealed, nil); !ok {
+		t.Fatal("cannot decrypt")
+	}
+
+	// the minimum is serialized
+	s.SetMinMACLength(300)
+	serialized := s.Serialize()
+	if recovered := RecoverState(serialized); recovered.MinMACLength() != 300 {
+		t.Fatal("the minimum tag length is not serialized")
+	}
+	// and is the default for the states serialized without it
+	legacy := RecoverState(serialized[:len(serialized)-4])
+	if legacy.MinMACLength() != 1 || legacy.debugPrintState() != s.debugPrintState() {
+		t.Fatal("cannot recover a state serialized without its minimum tag length")
+	}
+}
+
+func TestAbortOnFailure(t *testing.T) {
+	s := InitStrobe("myHash", 128)
+	s.KEY([]byte("key"))
+	sender := s
+	tag := sender.Send_MAC(false, MACLEN)
+
+	// without the mode, the state can still be used
+	receiver := s
+	if receiver.Recv_MAC(false, make([]byte, MACLEN)) || receiver.Poisoned() {
+		t.Fatal("invalid tag accepted, or state poisoned")
+	}
+	receiver.PRF(16)
+
+	// the mode is serialized
+	s.SetAbortOnFailure(true)
+	s = RecoverState(s.Serialize())
+
+	// a valid tag does not poison the state
+	receiver = s
+	if !receiver.Recv_MAC(false, tag) || receiver.Poisoned() {
+		t.Fatal("cannot verify tag in abort on failure mode")
+	}
+	receiver.PRF(16)
+
+	failures := []func(r *Strobe){
+		func(r *Strobe) { r.Recv_MAC(false, make([]byte, MACLEN)) },
+		func(r *Strobe) { r.Recv_MAC(false, nil) },
+		func(r *Strobe) { r.Operate(false, "recv_MAC", make([]byte, MACLEN), 0, false) },
+		func(r *Strobe) { r.Recv_AEAD(make([]byte, 2*MACLEN), nil) },
+		func(r *Strobe) { r.Recv_AEAD(make([]byte, MACLEN-1), nil) },
+		func(r *Strobe) { r.RecvAEAD(nil, make([]byte, MACLEN-1), nil) },
+		func(r *Strobe) {
+			v := r.NewMACVerifier(false)
+			v.Write(make([]byte, MACLEN))
+			v.Verify()
+		},
+	}
+	for i, fail := range failures {
+		receiver := s
+		fail(&receiver)
+		if !receiver.Poisoned() {
+			t.Fatalf("%d: state not poisoned", i)
+		}
+		if _, err := receiver.TryOperate(false, "PRF", nil, 16, false); err != ErrStatePoisoned {
+			t.Fatalf("%d: expected ErrStatePoisoned, got %v", i, err)
+		}
+		for j, refused := range []func(){
+			func() { receiver.AD(false, message) },
+			func() { receiver.Clone() },
+			func() { receiver.Serialize() },
+		} {
+			func() {
+				defer func() {
+					if r := recover(); r != ErrStatePoisoned {
+						t.Fatalf("%d-%d: expected a panic with ErrStatePoisoned, got %v", i, j, r)
+					}
+				}()
+				refused()
+			}()
+		}
+	}
+}
+
+func TestRoles(t *testing.T) {
+	s := InitStrobe("myProtocol", 128)
+	s.KEY([]byte("key"))
+	if s.Role() != NoRole {
+		t.Fatal("role is set before any transport operation")
+	}
+
+	// implicit roles
+	implicitA, implicitB := s, s
+	implicitA.Send_CLR(false, message)
+	implicitB.Recv_CLR(false, message)
+	if implicitA.Role() != Initiator || implicitB.Role() != Responder {
+		t.Fatal("roles are not set by the first transport operation")
+	}
+	if err := implicitA.SetRole(Responder); err != ErrRoleAlreadySet {
+		t.Fatal("expected ErrRoleAlreadySet, got", err)
+	}
+
+	// pre-assigned roles: the responder sends first
+	a, b := s, s
+	if err := a.SetRole(Initiator); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetRole(Responder); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetRole(Responder); err != ErrRoleAlreadySet {
+		t.Fatal("expected ErrRoleAlreadySet, got", err)
+	}
+	b.Send_CLR(false, message)
+	a.Recv_CLR(false, message)
+	if a.Role() != Initiator || b.Role() != Responder {
+		t.Fatal("pre-assigned roles are overwritten")
+	}
+	b.Recv_MAC(false, a.Send_MAC(false, 16))
+	if a.debugPrintState() != b.debugPrintState() {
+		t.Fatal("parties with pre-assigned roles diverge")
+	}
+	// the direction is authenticated
+	if a.debugPrintState() == implicitA.debugPrintState() {
+		t.Fatal("the direction of the first message is not absorbed")
+	}
+
+	// symmetric roles: the direction is not absorbed
+	sym := func(firstSender bool) string {
+		a, b := s, s
+		a.SetRole(Symmetric)
+		b.SetRole(Symmetric)
+		if firstSender {
+			b.Recv_ENC_unauthenticated(false, a.Send_ENC_unauthenticated(false, message))
+		} else {
+			a.Recv_ENC_unauthenticated(false, b.Send_ENC_unauthenticated(false, message))
+		}
+		if a.debugPrintState() != b.debugPrintState() {
+			t.Fatal("parties with symmetric roles diverge")
+		}
+		return a.debugPrintState()
+	}
+	if sym(true) != sym(false) {
+		t.Fatal("the direction is absorbed in symmetric mode")
+	}
+
+	// roles are serialized
+	b = RecoverState(b.Serialize())
+	if b.Role() != Responder {
+		t.Fatal("role is not serialized")
+	}
+	if err := b.SetRole(NoRole); err != ErrInvalidRole {
+		t.Fatal("expected ErrInvalidRole, got", err)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	standard := InitStrobe("myProtocol", 128)
+	for _, config := range []Config{{Security: 128}, {Security: 128, Rounds: 24}} {
+		s := InitStrobeWithConfig("myProtocol", config)
+		if s.debugPrintState() != standard.debugPrintState() {
+			t.Fatal("default Config is not standard")
+		}
+	}
+
+	states := map[string]bool{standard.debugPrintState(): true}
+	for _, rounds := range []int{4, 8, 12, 16, 20} {
+		s := InitStrobeWithConfig("myProtocol", Config{Security: 256, Rounds: rounds})
+		if states[s.debugPrintState()] {
+			t.Fatal("the number of rounds is not absorbed at initialization")
+		}
+		states[s.debugPrintState()] = true
+
+		s.AD(false, message)
+		recovered := RecoverState(s.Serialize())
+		if !bytes.Equal(recovered.PRF(32), s.PRF(32)) {
+			t.Fatal("the number of rounds is not serialized")
+		}
+	}
+
+	for _, rounds := range []int{-4, 3, 26, 28} {
+		if _, err := TryInitStrobeWithConfig("", Config{Security: 128, Rounds: rounds}); err != ErrInvalidRounds {
+			t.Fatal("expected ErrInvalidRounds, got", err)
+		}
+	}
+	if _, err := TryInitStrobeWithConfig("", Config{Rounds: 12}); err != ErrInvalidSecurity {
+		t.Fatal("expected ErrInvalidSecurity, got", err)
+	}
+
+	// encode_string of long domain strings
+	long := string(make([]byte, 40))
+	if !bytes.Equal(appendEncodeString(nil, long)[:3], []byte{2, 1, 64}) {
+		t.Fatal("invalid encode_string")
+	}
+	if !bytes.Equal(appendEncodeString(nil, "STROBEv1.0.2")[:2], []byte{1, 96}) {
+		t.Fatal("invalid encode_string")
+	}
+}
+
+func TestWidths(t *testing.T) {
+	for _, config := range []Config{
+		{Security: 128, Width: 800},
+		{Security: 256, Width: 800},
+		{Security: 128, Width: 400},
+		{Security: 64, Width: 200},
+		{Security: 64, Width: 200, Rounds: 9},
+	} {
+		s := InitStrobeWithConfig("myProtocol", config)
+		if len(s.stateBytes()) != config.Width/8 {
+			t.Fatal("invalid state size")
+		}
+		if s.StrobeR != config.Width/8-config.Security/4-2 {
+			t.Fatal("invalid rate")
+		}
+		s.KEY([]byte("key"))
+		s.AD(false, bytes.Repeat(message, 4))
+
+		// both parties agree
+		a, b := s, s
+		ciphertext := a.Send_AEAD(message, nil)
+		plaintext, ok := b.Recv_AEAD(ciphertext, nil)
+		if !ok || !bytes.Equal(plaintext, message) {
+			t.Fatal("cannot decrypt")
+		}
+
+		// serialization
+		recovered := RecoverState(a.Serialize())
+		if !bytes.Equal(recovered.PRF(64), b.PRF(64)) {
+			t.Fatal("serialization does not preserve the width")
+		}
+	}
+
+	for _, config := range []Config{
+		{Security: 128, Width: 1000},
+		{Security: 128, Width: 200},
+		{Security: 256, Width: 400},
+		{Security: 64},
+		{Security: 128, Width: 800, Rounds: 23},
+	} {
+		if _, err := TryInitStrobeWithConfig("", config); err == nil {
+			t.Fatalf("invalid config %+v accepted", config)
+		}
+	}
+}
+
+// benchmarkSizes are the small messages for which the cost of an operation
+// is mostly its fixed cost.
+var benchmarkSizes = []int{16, 64}
+
+func benchmarkOperation(b *testing.B, operation func(s *Strobe, data []byte)) {
+	for _, size := range benchmarkSizes {
+		b.Run(fmt.Sprintf("%dbytes", size), func(b *testing.B) {
+			s := InitStrobe("benchmark", 128)
+			s.KEY([]byte("key"))
+			data := make([]byte, size)
+			b.SetBytes(int64(size))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				operation(&s, data)
+			}
+		})
+	}
+}
+
+func BenchmarkSendENC(b *testing.B) {
+	benchmarkOperation(b, func(s *Strobe, data []byte) { s.Send_ENC_unauthenticated(false, data) })
+}
+
+func BenchmarkRecvENC(b *testing.B) {
+	benchmarkOperation(b, func(s *Strobe, data []byte) { s.Recv_ENC_unauthenticated(false, data) })
+}
+
+func BenchmarkPRF(b *testing.B) {
+	benchmarkOperation(b, func(s *Strobe, data []byte) { s.PRFInto(data) })
 }
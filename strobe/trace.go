@@ -0,0 +1,108 @@
+package strobe
+
+// TraceKind says at which point of an operation a TraceEvent is produced.
+type TraceKind uint8
+
+const (
+	// TraceBeginOp is produced when an operation starts (that is, when it is
+	// not the continuation of a streamed operation), after its flags are
+	// absorbed.
+	TraceBeginOp TraceKind = iota
+	// TraceOperate is produced after an operation (or a chunk of a streamed
+	// operation) has processed its data.
+	TraceOperate
+	// TraceSetRole is produced when the role of the party is set by SetRole.
+	TraceSetRole
+)
+
+// String returns the name of the trace kind.
+func (k TraceKind) String() string {
+	switch k {
+	case TraceBeginOp:
+		return "beginOp"
+	case TraceSetRole:
+		return "setRole"
+	}
+	return "operate"
+}
+
+// TraceEvent describes a step of an operation run on a Strobe state.
+type TraceEvent struct {
+	Kind TraceKind
+	Op   Operation
+	Meta bool
+	More bool // continuation of a streamed operation
+	Role Role // the role set, for TraceSetRole events
+
+	// InputLength is the length of the input data, or the requested length
+	// for PRF, send_MAC and RATCHET. OutputLength is the length of the output
+	// (1 for recv_MAC, whose output is 0 if the MAC is correct).
+	InputLength  int
+	OutputLength int
+
+	// Input and Output are only set for TraceOperate events when the tracer
+	// was attached with data, see SetTracer. They must not be retained.
+	Input  []byte
+	Output []byte
+
+	// State is the state after the event.
+	State []byte
+}
+
+// Tracer is called on every step of every operation run on the Strobe state
+// it is attached to, see SetTracer.
+type Tracer interface {
+	Trace(event *TraceEvent)
+}
+
+// SetTracer attaches `tracer` to the state, or detaches the current tracer
+// if it is nil. If `withData` is true, the events include the input and
+// output data of the operations. Copies of the state share the tracer. When
+// no tracer is attached, tracing has no cost.
+func (s *Strobe) SetTracer(tracer Tracer, withData bool) {
+	s.tracer = tracer
+	s.traceData = withData
+}
+
+// traceBeginOp produces a TraceBeginOp event.
+func (s *Strobe) traceBeginOp(flags flag) {
+	s.tracer.Trace(&TraceEvent{
+		Kind:  TraceBeginOp,
+		Op:    Operation(flags &^ flagM),
+		Meta:  flags&flagM != 0,
+		State: s.stateBytes(),
+	})
+}
+
+// traceOperate produces a TraceOperate event. `output` is the output buffer
+// of the operation (or nil), `length` the length of the data processed.
+func (s *Strobe) traceOperate(flags flag, input, output []byte, length int, failures byte, more bool) {
+	event := &TraceEvent{
+		Kind:        TraceOperate,
+		Op:          Operation(flags &^ flagM),
+		Meta:        flags&flagM != 0,
+		More:        more,
+		InputLength: length,
+		State:       s.stateBytes(),
+	}
+	switch {
+	case flags.isRecvMAC():
+		event.OutputLength = 1
+		output = []byte{failures}
+	case flags.hasOutput():
+		event.OutputLength = length
+		if output == nil {
+			// send_CLR and recv_CLR do not modify their data
+			output = input
+		}
+	default:
+		output = nil
+	}
+	if s.traceData {
+		if !flags.needsLength() {
+			event.Input = input
+		}
+		event.Output = output
+	}
+	s.tracer.Trace(event)
+}
@@ -0,0 +1,77 @@
+package strobe
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+type eventsTracer struct {
+	events []TraceEvent
+}
+
+func (et *eventsTracer) Trace(event *TraceEvent) {
+	event.Input = append([]byte(nil), event.Input...)
+	event.Output = append([]byte(nil), event.Output...)
+	et.events = append(et.events, *event)
+}
+
+func TestTracer(t *testing.T) {
+	s := InitStrobe("myHash", 128)
+	tracer := &eventsTracer{}
+	s.SetTracer(tracer, true)
+
+	s.KEY([]byte("key"))
+	ciphertext := s.Send_ENC_unauthenticated(true, message)
+	s.Operate(false, "AD", message, 0, false)
+	s.Operate(false, "AD", message, 0, true)
+	prf := s.PRF(32)
+	s.Recv_MAC(false, make([]byte, 16))
+	final := s.debugPrintState()
+
+	expected := []TraceEvent{
+		{Kind: TraceBeginOp, Op: OpKEY},
+		{Kind: TraceOperate, Op: OpKEY, InputLength: 3, Input: []byte("key")},
+		{Kind: TraceBeginOp, Op: OpSendENC, Meta: true},
+		{Kind: TraceOperate, Op: OpSendENC, Meta: true, InputLength: len(message), OutputLength: len(message), Input: message, Output: ciphertext},
+		{Kind: TraceBeginOp, Op: OpAD},
+		{Kind: TraceOperate, Op: OpAD, InputLength: len(message), Input: message},
+		{Kind: TraceOperate, Op: OpAD, More: true, InputLength: len(message), Input: message},
+		{Kind: TraceBeginOp, Op: OpPRF},
+		{Kind: TraceOperate, Op: OpPRF, InputLength: 32, OutputLength: 32, Output: prf},
+		{Kind: TraceBeginOp, Op: OpRecvMAC},
+		{Kind: TraceOperate, Op: OpRecvMAC, InputLength: 16, OutputLength: 1, Input: make([]byte, 16)},
+	}
+	if len(tracer.events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(tracer.events))
+	}
+	for i, event := range tracer.events {
+		e := expected[i]
+		if event.Kind != e.Kind || event.Op != e.Op || event.Meta != e.Meta || event.More != e.More ||
+			event.InputLength != e.InputLength || event.OutputLength != e.OutputLength ||
+			!bytes.Equal(event.Input, e.Input) || (e.Output != nil && !bytes.Equal(event.Output, e.Output)) {
+			t.Fatalf("%d: unexpected event %+v", i, event)
+		}
+	}
+	if failures := tracer.events[len(expected)-1].Output; len(failures) != 1 || failures[0] == 0 {
+		t.Fatal("recv_MAC output is not traced")
+	}
+	if hex.EncodeToString(tracer.events[len(expected)-1].State) != final {
+		t.Fatal("state is not traced")
+	}
+
+	// without data
+	tracer.events = nil
+	s.SetTracer(tracer, false)
+	s.Send_ENC_unauthenticated(false, message)
+	if len(tracer.events) != 2 || tracer.events[1].Input != nil || tracer.events[1].Output != nil {
+		t.Fatal("data is traced")
+	}
+
+	// detached
+	s.SetTracer(nil, false)
+	s.PRF(16)
+	if len(tracer.events) != 2 {
+		t.Fatal("tracer is not detached")
+	}
+}
@@ -1,6 +1,7 @@
 package strobe
 
 import (
+	"bytes"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -8,24 +9,7 @@ import (
 	"testing"
 )
 
-// Operation is holding a test vector operation
-type Operation struct {
-	OpName string `json:"name"`
-
-	// for Init
-	OpCustomString string `json:"custom_string,omitempty"`
-	OpSecurity     int    `json:"security,omitempty"`
-
-	// for other operations
-	OpMeta        bool   `json:"meta"`
-	OpInputData   string `json:"input_data,omitempty"`
-	OpInputLength int    `json:"input_length,omitempty"`
-	OpOutput      string `json:"output,omitempty"`
-	OpStateAfter  string `json:"state_after"`
-	OpStream      bool   `json:"stream"`
-}
-
-func DebugInit(customString string, security int) (_ Strobe, op Operation) {
+func DebugInit(customString string, security int) (_ Strobe, op VectorOperation) {
 
 	s := InitStrobe(customString, security)
 
@@ -38,7 +22,25 @@ func DebugInit(customString string, security int) (_ Strobe, op Operation) {
 	return s, op
 }
 
-func (s *Strobe) DebugGoThroughOperation(operation string, meta bool, inputData []byte, inputLength int, stream bool) (op Operation) {
+func DebugInitWithConfig(customString string, config Config) (_ Strobe, op VectorOperation) {
+
+	s := InitStrobeWithConfig(customString, config)
+
+	op.OpName = "init"
+	op.OpCustomString = customString
+	op.OpSecurity = config.Security
+	op.OpWidth = config.Width
+	op.OpRounds = config.Rounds
+	if config.Permutation != nil {
+		op.OpPermutation = config.Permutation.Name()
+	}
+	op.OpStateAfter = s.debugPrintState()
+
+	//
+	return s, op
+}
+
+func (s *Strobe) DebugGoThroughOperation(operation string, meta bool, inputData []byte, inputLength int, stream bool) (op VectorOperation) {
 	// create operation object
 	op.OpName = operation
 	op.OpInputData = hex.EncodeToString(inputData)
@@ -56,15 +58,6 @@ func (s *Strobe) DebugGoThroughOperation(operation string, meta bool, inputData
 	return
 }
 
-type TestVector struct {
-	Name       string      `json:"name"`
-	Operations []Operation `json:"operations"`
-}
-
-type TestVectors struct {
-	TestVectors []TestVector `json:"test_vectors"`
-}
-
 func TestGenTestVectors(t *testing.T) {
 	// skipping this
 	if testing.Short() {
@@ -86,6 +79,12 @@ func TestGenTestVectors(t *testing.T) {
 	testVectors.TestVectors = append(testVectors.TestVectors, metaTest())
 	testVectors.TestVectors = append(testVectors.TestVectors, streamingTest())
 	testVectors.TestVectors = append(testVectors.TestVectors, boundaryBlocksTest())
+	for _, rounds := range []int{4, 8, 12, 16, 20} {
+		testVectors.TestVectors = append(testVectors.TestVectors, roundsTest(rounds))
+	}
+	for _, config := range []Config{{Security: 128, Width: 800}, {Security: 256, Width: 800}, {Security: 128, Width: 400}, {Security: 64, Width: 200}, {Security: 128, Width: 800, Rounds: 12}, {Security: 128, Permutation: NewXoodoo()}} {
+		testVectors.TestVectors = append(testVectors.TestVectors, permutationTest(config))
+	}
 
 	// save output
 	jsonOutput, _ := json.Marshal(testVectors)
@@ -195,3 +194,54 @@ func boundaryBlocksTest() (testVector TestVector) {
 	testVector.Operations = append(testVector.Operations, s.DebugGoThroughOperation("send_MAC", true, []byte{}, 16, false))
 	return
 }
+
+func roundsTest(rounds int) (testVector TestVector) {
+	// start the run
+	testVector.Name = fmt.Sprintf("%d rounds tests", rounds)
+	// init
+	s, op := DebugInitWithConfig("custom string number 2, that's a pretty long string", Config{Security: 128, Rounds: rounds})
+	testVector.Operations = append(testVector.Operations, op)
+	// KEY
+	key := []byte("010101")
+	testVector.Operations = append(testVector.Operations, s.DebugGoThroughOperation("KEY", false, key, 0, false))
+	// AD over several blocks
+	message := bytes.Repeat([]byte("hello, how are you good sir?"), 10)
+	testVector.Operations = append(testVector.Operations, s.DebugGoThroughOperation("AD", false, message, 0, false))
+	// send_ENC
+	testVector.Operations = append(testVector.Operations, s.DebugGoThroughOperation("send_ENC", false, []byte("hi how are you"), 0, false))
+	// send_MAC so there's an output vector at the end of this test
+	testVector.Operations = append(testVector.Operations, s.DebugGoThroughOperation("send_MAC", false, []byte{}, 16, false))
+	return
+}
+
+func permutationTest(config Config) (testVector TestVector) {
+	// start the run
+	testVector.Name = fmt.Sprintf("keccak-f[%d] security %d tests", config.Width, config.Security)
+	if config.Rounds != 0 {
+		testVector.Name = fmt.Sprintf("keccak-p[%d,%d] security %d tests", config.Width, config.Rounds, config.Security)
+	}
+	if config.Permutation != nil {
+		testVector.Name = fmt.Sprintf("%s security %d tests", config.Permutation.Name(), config.Security)
+	}
+	// init
+	s, op := DebugInitWithConfig("custom string number 2, that's a pretty long string", config)
+	testVector.Operations = append(testVector.Operations, op)
+	// KEY
+	key := []byte("0101010100100101010101010101001001")
+	testVector.Operations = append(testVector.Operations, s.DebugGoThroughOperation("KEY", false, key, 0, false))
+	// AD over several blocks
+	message := []byte("hello, how are you good sir? ????")
+	testVector.Operations = append(testVector.Operations, s.DebugGoThroughOperation("AD", true, message, 0, false))
+	testVector.Operations = append(testVector.Operations, s.DebugGoThroughOperation("AD", true, message, 0, true))
+	// PRF + RATCHET
+	testVector.Operations = append(testVector.Operations, s.DebugGoThroughOperation("PRF", false, []byte{}, 40, false))
+	testVector.Operations = append(testVector.Operations, s.DebugGoThroughOperation("RATCHET", false, []byte{}, 32, false))
+	// transport
+	testVector.Operations = append(testVector.Operations, s.DebugGoThroughOperation("send_ENC", false, []byte("hi how are you"), 0, false))
+	testVector.Operations = append(testVector.Operations, s.DebugGoThroughOperation("send_MAC", false, []byte{}, 16, false))
+	testVector.Operations = append(testVector.Operations, s.DebugGoThroughOperation("recv_ENC", false, []byte("hi how are you"), 0, false))
+	testVector.Operations = append(testVector.Operations, s.DebugGoThroughOperation("recv_CLR", true, []byte("hi how are you"), 0, false))
+	// send_MAC so there's an output vector at the end of this test
+	testVector.Operations = append(testVector.Operations, s.DebugGoThroughOperation("send_MAC", true, []byte{}, 16, false))
+	return
+}
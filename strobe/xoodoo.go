@@ -0,0 +1,91 @@
+package strobe
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+//
+// Xoodoo
+//
+// Xoodoo[12] is a 384-bit permutation of 3 planes of 4 32-bit lanes, cheaper
+// than Keccak-f on 32-bit cores. Strobe on Xoodoo[12] absorbs its own domain
+// string, "STROBEv1.0.2/Xoodoo[12]", and only supports 128-bit security: its
+// rate is 48 - 128/4 = 16 bytes.
+//
+
+// xoodooSize is the size of the Xoodoo state in bytes.
+const xoodooSize = 384 / 8
+
+// xoodooRoundConstants are the round constants of Xoodoo[12], in order.
+var xoodooRoundConstants = [12]uint32{
+	0x00000058, 0x00000038, 0x000003C0, 0x000000D0, 0x00000120, 0x00000014,
+	0x00000060, 0x0000002C, 0x00000380, 0x000000F0, 0x000001A0, 0x00000012,
+}
+
+// xoodooPermutation is Xoodoo[12] on a state of 12 little-endian lanes,
+// lane x of plane y being at index x + 4*y.
+type xoodooPermutation struct{}
+
+// NewXoodoo returns the Xoodoo[12] permutation, to be used as the
+// Permutation of a Config.
+func NewXoodoo() Permutation {
+	return xoodooPermutation{}
+}
+
+func (xoodooPermutation) Size() int {
+	return xoodooSize
+}
+
+func (xoodooPermutation) Name() string {
+	return "Xoodoo[12]"
+}
+
+func (xoodooPermutation) Permute(state []byte) {
+	var a [12]uint32
+	for i := range a {
+		a[i] = binary.LittleEndian.Uint32(state[4*i:])
+	}
+	xoodoo(&a)
+	for i, lane := range a {
+		binary.LittleEndian.PutUint32(state[4*i:], lane)
+	}
+}
+
+// xoodoo applies Xoodoo[12] to the lanes `a`.
+func xoodoo(a *[12]uint32) {
+	var p, e [4]uint32
+	var b [12]uint32
+	for _, rc := range xoodooRoundConstants {
+		// θ: column parity mixer
+		for x := 0; x < 4; x++ {
+			p[x] = a[x] ^ a[x+4] ^ a[x+8]
+		}
+		for x := 0; x < 4; x++ {
+			e[x] = bits.RotateLeft32(p[(x+3)%4], 5) ^ bits.RotateLeft32(p[(x+3)%4], 14)
+		}
+		for i := range a {
+			a[i] ^= e[i%4]
+		}
+		// ρwest: A1 ⋘ (1, 0), A2 ⋘ (0, 11)
+		for x := 0; x < 4; x++ {
+			b[x] = a[x]
+			b[x+4] = a[(x+3)%4+4]
+			b[x+8] = bits.RotateLeft32(a[x+8], 11)
+		}
+		// ι
+		b[0] ^= rc
+		// χ
+		for x := 0; x < 4; x++ {
+			a[x] = b[x] ^ ^b[x+4]&b[x+8]
+			a[x+4] = b[x+4] ^ ^b[x+8]&b[x]
+			a[x+8] = b[x+8] ^ ^b[x]&b[x+4]
+		}
+		// ρeast: A1 ⋘ (0, 1), A2 ⋘ (2, 8)
+		for x := 0; x < 4; x++ {
+			b[x+4] = bits.RotateLeft32(a[x+4], 1)
+			b[x+8] = bits.RotateLeft32(a[(x+2)%4+8], 8)
+		}
+		copy(a[4:], b[4:])
+	}
+}
@@ -0,0 +1,110 @@
+package strobe
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/bits"
+	"testing"
+)
+
+// xoodooReference is a transcription of the Xoodoo reference code, to check
+// the implementation of xoodoo.
+func xoodooReference(a *[12]uint32) {
+	index := func(x, y int) int { return (x+4)%4 + 4*((y+3)%3) }
+	for _, rc := range xoodooRoundConstants {
+		var p, e [4]uint32
+		var b [12]uint32
+		for x := 0; x < 4; x++ {
+			p[x] = a[index(x, 0)] ^ a[index(x, 1)] ^ a[index(x, 2)]
+		}
+		for x := 0; x < 4; x++ {
+			e[x] = bits.RotateLeft32(p[(x+3)%4], 5) ^ bits.RotateLeft32(p[(x+3)%4], 14)
+		}
+		for x := 0; x < 4; x++ {
+			for y := 0; y < 3; y++ {
+				a[index(x, y)] ^= e[x]
+			}
+		}
+		for x := 0; x < 4; x++ {
+			b[index(x, 0)] = a[index(x, 0)]
+			b[index(x, 1)] = a[index(x-1, 1)]
+			b[index(x, 2)] = bits.RotateLeft32(a[index(x, 2)], 11)
+		}
+		*a = b
+		a[0] ^= rc
+		for x := 0; x < 4; x++ {
+			for y := 0; y < 3; y++ {
+				b[index(x, y)] = a[index(x, y)] ^ (^a[index(x, y+1)] & a[index(x, y+2)])
+			}
+		}
+		*a = b
+		for x := 0; x < 4; x++ {
+			b[index(x, 0)] = a[index(x, 0)]
+			b[index(x, 1)] = bits.RotateLeft32(a[index(x, 1)], 1)
+			b[index(x, 2)] = bits.RotateLeft32(a[index(x+2, 2)], 8)
+		}
+		*a = b
+	}
+}
+
+func TestXoodyakHash(t *testing.T) {
+	// Xoodyak's hash of the empty message (Count = 1 of the Xoodyak KATs of
+	// the NIST lightweight cryptography competition) checks Xoodoo[12]
+	// through the Cyclist hash mode: Absorb is a single Down of an empty
+	// block, and each 16-byte block of Squeeze is an Up, with a Down of an
+	// empty block between them.
+	p := NewXoodoo()
+	state := make([]byte, p.Size())
+	state[0] ^= 0x01  // padding of the empty block
+	state[47] ^= 0x01 // Cd of the first block
+	p.Permute(state)
+	digest := append([]byte{}, state[:16]...)
+	state[0] ^= 0x01
+	p.Permute(state)
+	digest = append(digest, state[:16]...)
+
+	expected, _ := hex.DecodeString("ea152f2b47bce24efb66c479d4adf17bd324d806e85ff75ee369ee50dc8f8bd1")
+	if !bytes.Equal(digest, expected) {
+		t.Fatalf("invalid Xoodyak hash: %x", digest)
+	}
+}
+
+func TestXoodoo(t *testing.T) {
+	var expected, actual [12]uint32
+	for i := range expected {
+		expected[i] = uint32(i) * 0x01234567
+	}
+	actual = expected
+	for i := 0; i < 3; i++ {
+		xoodooReference(&expected)
+		xoodoo(&actual)
+	}
+	if expected != actual {
+		t.Fatal("xoodoo differs from the reference")
+	}
+
+	p := NewXoodoo()
+	if p.Size() != 48 || p.Name() != "Xoodoo[12]" {
+		t.Fatal("invalid Xoodoo[12]")
+	}
+
+	s := InitStrobeWithConfig("myProtocol", Config{Security: 128, Permutation: p})
+	if s.duplexRate != 16 || s.StrobeR != 14 {
+		t.Fatal("invalid rate")
+	}
+	s.KEY([]byte("key"))
+	a, b := s, s
+	ciphertext := a.Send_AEAD(bytes.Repeat(message, 3), nil)
+	plaintext, ok := b.Recv_AEAD(ciphertext, nil)
+	if !ok || !bytes.Equal(plaintext, bytes.Repeat(message, 3)) {
+		t.Fatal("cannot decrypt")
+	}
+	recovered := RecoverState(a.Serialize())
+	if !bytes.Equal(recovered.PRF(32), b.PRF(32)) {
+		t.Fatal("serialization does not preserve the permutation")
+	}
+
+	if _, err := TryInitStrobeWithConfig("", Config{Security: 256, Permutation: p}); err != ErrInvalidSecurity {
+		t.Fatal("expected ErrInvalidSecurity, got", err)
+	}
+}